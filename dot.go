@@ -0,0 +1,39 @@
+package bitradix
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// DOT writes the tree r as a Graphviz digraph to w. Nodes holding a key are
+// labeled with the key, the number of significant bits and the value as
+// returned by label, nodes without a key are drawn as a small circle. Edges
+// are labeled with the branch taken. The default route is drawn as a node of
+// its own, attached to the root with a dashed edge labeled "default".
+func (r *Radix64[T]) DOT(w io.Writer, label func(T) string) error {
+	b := bufio.NewWriter(w)
+	ids := map[*Radix64[T]]int{}
+	fmt.Fprintln(b, "digraph bitradix {")
+	r.Do(func(r1 *Radix64[T], i int) {
+		id := len(ids)
+		ids[r1] = id
+		if r1.bits > 0 {
+			l := fmt.Sprintf("%016x/%d\n%s", r1.key, r1.bits, label(r1.Value))
+			fmt.Fprintf(b, "\tn%d [shape=box, label=%s];\n", id, strconv.Quote(l))
+		} else {
+			fmt.Fprintf(b, "\tn%d [shape=circle, style=dashed, label=\"\"];\n", id)
+		}
+		if i >= 0 {
+			fmt.Fprintf(b, "\tn%d -> n%d [label=\"%d\"];\n", ids[as64(r1.parent)], id, i)
+		}
+	})
+	if r.dflt != nil {
+		l := fmt.Sprintf("%016x/0\n%s", r.dflt.key, label(r.dflt.Value))
+		fmt.Fprintf(b, "\tn%d [shape=box, label=%s];\n", len(ids), strconv.Quote(l))
+		fmt.Fprintf(b, "\tn%d -> n%d [label=\"default\", style=dashed];\n", ids[r], len(ids))
+	}
+	fmt.Fprintln(b, "}")
+	return b.Flush()
+}
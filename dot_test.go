@@ -0,0 +1,58 @@
+package bitradix
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDOT(t *testing.T) {
+	r := New64[uint32]()
+	r.Insert(0x8000000000000000, bits32, 2012)
+	r.Insert(0x4000000000000000, bits32, 2010)
+	r.Insert(0x9000000000000000, bits32, 2013)
+	buf := &bytes.Buffer{}
+	if err := r.DOT(buf, func(v uint32) string { return strconv.Itoa(int(v)) }); err != nil {
+		t.Fatalf("Failed to write: %s\n", err)
+	}
+	t.Logf("%s", buf)
+	out := buf.String()
+	if !strings.HasPrefix(out, "digraph bitradix {\n") || !strings.HasSuffix(out, "}\n") {
+		t.Logf("Expected a digraph\n")
+		t.Fail()
+	}
+	// The root, the 3 keys and 3 edges
+	if n := strings.Count(out, "shape=box"); n != 3 {
+		t.Logf("Expected %d key nodes, got %d\n", 3, n)
+		t.Fail()
+	}
+	if n := strings.Count(out, "shape=circle"); n != 1 {
+		t.Logf("Expected %d internal nodes, got %d\n", 1, n)
+		t.Fail()
+	}
+	if n := strings.Count(out, "->"); n != 3 {
+		t.Logf("Expected %d edges, got %d\n", 3, n)
+		t.Fail()
+	}
+	for _, s := range []string{`n0 -> n1 [label="0"]`, `n0 -> n2 [label="1"]`, `n2 -> n3 [label="0"]`, `8000000000000000/5\n2012`} {
+		if !strings.Contains(out, s) {
+			t.Logf("Expected %s in output\n", s)
+			t.Fail()
+		}
+	}
+
+	// The default route gets a node of its own, attached to the root.
+	r.Insert(0, 0, 1)
+	buf.Reset()
+	if err := r.DOT(buf, func(v uint32) string { return strconv.Itoa(int(v)) }); err != nil {
+		t.Fatalf("Failed to write: %s\n", err)
+	}
+	out = buf.String()
+	for _, s := range []string{`n4 [shape=box, label="0000000000000000/0\n1"]`, `n0 -> n4 [label="default", style=dashed]`} {
+		if !strings.Contains(out, s) {
+			t.Logf("Expected %s in output\n", s)
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package bitradix
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Every key is encoded as a record: the key (8 bytes), the number of
+// significant bits (1 byte) and the length of the value (4 bytes), all big
+// endian, followed by the encoded value.
+const recordHeader64 = 8 + 1 + 4
+
+// Encode writes all keys in the tree r to w, in the order of Keys. The values
+// are converted to bytes with encode. It returns the number of bytes written.
+func (r *Radix64[T]) Encode(w io.Writer, encode func(T) ([]byte, error)) (int64, error) {
+	var (
+		n   int64
+		hdr [recordHeader64]byte
+	)
+	for _, x := range r.sorted() {
+		v, err := encode(x.Value)
+		if err != nil {
+			return n, err
+		}
+		binary.BigEndian.PutUint64(hdr[0:], x.key)
+		hdr[8] = byte(x.bits)
+		binary.BigEndian.PutUint32(hdr[9:], uint32(len(v)))
+		m, err := w.Write(hdr[:])
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+		m, err = w.Write(v)
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// Decode reads keys written by Encode from rd until io.EOF and inserts them in
+// the tree r. The values are converted back with decode. It returns the
+// number of bytes read, r must be the root of the tree.
+func (r *Radix64[T]) Decode(rd io.Reader, decode func([]byte) (T, error)) (int64, error) {
+	var (
+		n   int64
+		hdr [recordHeader64]byte
+	)
+	for {
+		m, err := io.ReadFull(rd, hdr[:])
+		n += int64(m)
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		key := binary.BigEndian.Uint64(hdr[0:])
+		bits := int(hdr[8])
+		// Copy the value instead of allocating the length from the header up
+		// front, a corrupt header could claim up to 4 GiB.
+		v := &bytes.Buffer{}
+		c, err := io.CopyN(v, rd, int64(binary.BigEndian.Uint32(hdr[9:])))
+		n += c
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return n, err
+		}
+		value, err := decode(v.Bytes())
+		if err != nil {
+			return n, fmt.Errorf("bitradix: decoding value of %064b/%d: %w", key, bits, err)
+		}
+		if _, err := r.InsertE(key, bits, value); err != nil {
+			return n, err
+		}
+	}
+}
+
+// BinaryRadix64 is a Radix64 holding strings or byte slices. It implements
+// encoding.BinaryMarshaler and encoding.BinaryUnmarshaler, so it can be used
+// with encoding/gob and friends.
+type BinaryRadix64[T ~string | ~[]byte] struct {
+	*Radix64[T]
+}
+
+// NewBinary64 returns an empty, initialized BinaryRadix64 tree.
+func NewBinary64[T ~string | ~[]byte]() *BinaryRadix64[T] {
+	return &BinaryRadix64[T]{New64[T]()}
+}
+
+// MarshalBinary returns all keys in the tree in the format of Encode.
+func (b BinaryRadix64[T]) MarshalBinary() ([]byte, error) {
+	if b.Radix64 == nil {
+		return nil, nil
+	}
+	buf := &bytes.Buffer{}
+	_, err := b.Encode(buf, func(v T) ([]byte, error) { return []byte(v), nil })
+	return buf.Bytes(), err
+}
+
+// UnmarshalBinary replaces the tree with the keys in data, which must be in
+// the format of Encode.
+func (b *BinaryRadix64[T]) UnmarshalBinary(data []byte) error {
+	b.Radix64 = New64[T]()
+	_, err := b.Decode(bytes.NewReader(data), func(v []byte) (T, error) { return T(v), nil })
+	return err
+}
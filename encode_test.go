@@ -0,0 +1,136 @@
+package bitradix
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"io"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+func encodeString(s string) ([]byte, error) { return []byte(s), nil }
+
+func decodeString(b []byte) (string, error) { return string(b), nil }
+
+func TestEncode(t *testing.T) {
+	r := New64[string]()
+	for k := uint64(0); k < 300; k++ {
+		r.Insert(k<<52, 12, strconv.FormatUint(k, 10))
+		if k%3 == 0 {
+			r.Insert(k<<52|1<<40, 24, strconv.FormatUint(k, 16))
+		}
+	}
+	buf := &bytes.Buffer{}
+	n, err := r.Encode(buf, encodeString)
+	if err != nil {
+		t.Fatalf("Failed to encode: %s\n", err)
+	}
+	if n != int64(buf.Len()) {
+		t.Logf("Expected %d bytes written, got %d\n", buf.Len(), n)
+		t.Fail()
+	}
+
+	d := New64[string]()
+	m, err := d.Decode(bytes.NewReader(buf.Bytes()), decodeString)
+	if err != nil {
+		t.Fatalf("Failed to decode: %s\n", err)
+	}
+	if m != n {
+		t.Logf("Expected %d bytes read, got %d\n", n, m)
+		t.Fail()
+	}
+	if d.Len() != r.Len() {
+		t.Logf("Expected %d keys, got %d\n", r.Len(), d.Len())
+		t.Fail()
+	}
+	r.Do(func(r1 *Radix64[string], _ int) {
+		if r1.bits == 0 {
+			return
+		}
+		if x := d.Find(r1.key, r1.bits); x == nil || x.Value != r1.Value {
+			t.Logf("Expected %s for %064b/%d\n", r1.Value, r1.key, r1.bits)
+			t.Fail()
+		}
+	})
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	r := New64[string]()
+	r.Insert(0x0A00000000000000, 8, "ten")
+	buf := &bytes.Buffer{}
+	r.Encode(buf, encodeString)
+	for _, l := range []int{5, buf.Len() - 1} {
+		_, err := New64[string]().Decode(bytes.NewReader(buf.Bytes()[:l]), decodeString)
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Logf("Expected %s for %d bytes, got %v\n", io.ErrUnexpectedEOF, l, err)
+			t.Fail()
+		}
+	}
+}
+
+func TestDecodeHugeLength(t *testing.T) {
+	// The header claims a value of almost 4 GiB, but the stream ends after 3
+	// bytes. This must fail without allocating the claimed length.
+	data := []byte{0x0A, 0, 0, 0, 0, 0, 0, 0, 8, 0xFF, 0xFF, 0xFF, 0xFF, 't', 'e', 'n'}
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	n, err := New64[string]().Decode(bytes.NewReader(data), decodeString)
+	runtime.ReadMemStats(&after)
+	if !errors.Is(err, io.ErrUnexpectedEOF) || n != int64(len(data)) {
+		t.Logf("Expected %s after %d bytes, got %v after %d\n", io.ErrUnexpectedEOF, len(data), err, n)
+		t.Fail()
+	}
+	if b := after.TotalAlloc - before.TotalAlloc; b > 1<<20 {
+		t.Logf("Expected less than 1 MiB to be allocated, got %d bytes\n", b)
+		t.Fail()
+	}
+}
+
+func TestGobBinaryRadix64(t *testing.T) {
+	r := NewBinary64[string]()
+	routes := map[uint64]string{
+		0x0A00000000000000: "10.0.0.0/8",
+		0x0A14000000000000: "10.20.0.0/14",
+		0xC0A8020000000000: "192.168.2.0/24",
+	}
+	bits := map[uint64]int{0x0A00000000000000: 8, 0x0A14000000000000: 14, 0xC0A8020000000000: 24}
+	for k, v := range routes {
+		r.Insert(k, bits[k], v)
+	}
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(r); err != nil {
+		t.Fatalf("Failed to encode: %s\n", err)
+	}
+	d := &BinaryRadix64[string]{}
+	if err := gob.NewDecoder(buf).Decode(d); err != nil {
+		t.Fatalf("Failed to decode: %s\n", err)
+	}
+	if d.Len() != len(routes) {
+		t.Logf("Expected %d keys, got %d\n", len(routes), d.Len())
+		t.Fail()
+	}
+	for k, v := range routes {
+		if x := d.Find(k, bits[k]); x == nil || x.Value != v {
+			t.Logf("Expected %s, got %v\n", v, x)
+			t.Fail()
+		}
+	}
+
+	// And with byte slices
+	b := NewBinary64[[]byte]()
+	b.Insert(0x0A00000000000000, 8, []byte{1, 2, 3})
+	data, err := b.MarshalBinary()
+	if err != nil {
+		t.Fatalf("Failed to marshal: %s\n", err)
+	}
+	c := &BinaryRadix64[[]byte]{}
+	if err := c.UnmarshalBinary(data); err != nil {
+		t.Fatalf("Failed to unmarshal: %s\n", err)
+	}
+	if x := c.Find(0x0A00000000000000, 8); x == nil || !bytes.Equal(x.Value, []byte{1, 2, 3}) {
+		t.Logf("Expected %v, got %v\n", []byte{1, 2, 3}, x)
+		t.Fail()
+	}
+}
@@ -0,0 +1,26 @@
+package bitradix_test
+
+import (
+	"fmt"
+
+	"github.com/miekg/bitradix/v2"
+)
+
+func ExampleRadix64_ReadOnly() {
+	r := bitradix.New64[string]()
+	r.Insert(0x0A00000000000000, 8, "ten")
+	r.Insert(0x0A01000000000000, 16, "ten-one")
+
+	ro := r.ReadOnly()
+	v, bits, _ := ro.Match(0x0A01020300000000)
+	fmt.Println(v, bits, ro.Len())
+
+	// The view has no methods that change the tree.
+	_, ok := any(ro).(interface {
+		Insert(uint64, int, string) *bitradix.Radix64[string]
+	})
+	fmt.Println(ok)
+	// Output:
+	// ten-one 16 2
+	// false
+}
@@ -0,0 +1,56 @@
+package bitradix
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Iter64 iterates over the keys of a Radix64 tree in ascending order of key and
+// then number of significant bits.
+//
+// The keys are collected when the iterator is created, inserting keys into the
+// tree afterwards is not seen by the iterator. Removing keys from the tree
+// while iterating is not defined.
+type Iter64[T any] struct {
+	nodes []*Radix64[T]
+	i     int
+}
+
+// Iterator returns an iterator over the keys stored in the tree r.
+func (r *Radix64[T]) Iterator() *Iter64[T] {
+	return &Iter64[T]{nodes: r.sorted(), i: -1}
+}
+
+// Next advances the iterator to the next key, it returns false when there are
+// no more keys. Next must be called before the first key can be read.
+func (it *Iter64[T]) Next() bool {
+	if it.i < len(it.nodes) {
+		it.i++
+	}
+	return it.i < len(it.nodes)
+}
+
+// SeekKey positions the iterator so that the next call to Next moves to the
+// smallest key that is equal to or larger than n. When there is no such key
+// the iterator is exhausted. It is not named Seek, as that suggests io.Seeker.
+func (it *Iter64[T]) SeekKey(n uint64) {
+	i, _ := slices.BinarySearchFunc(it.nodes, n, func(x *Radix64[T], n uint64) int {
+		return cmp.Compare(x.key, n)
+	})
+	it.i = i - 1
+}
+
+// Key returns the current key.
+func (it *Iter64[T]) Key() uint64 {
+	return it.nodes[it.i].key
+}
+
+// Bits returns the number of significant bits of the current key.
+func (it *Iter64[T]) Bits() int {
+	return it.nodes[it.i].bits
+}
+
+// Value returns the value stored under the current key.
+func (it *Iter64[T]) Value() T {
+	return it.nodes[it.i].Value
+}
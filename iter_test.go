@@ -0,0 +1,80 @@
+package bitradix
+
+import "testing"
+
+func TestIterator(t *testing.T) {
+	r := New64[uint64]()
+	keys := []uint64{0x90, 0x10, 0x80, 0x40, 0xF0, 0x20}
+	for _, k := range keys {
+		r.Insert(k<<56, 4, k)
+	}
+	expected := []uint64{0x10, 0x20, 0x40, 0x80, 0x90, 0xF0}
+	i := 0
+	it := r.Iterator()
+	for it.Next() {
+		if i >= len(expected) {
+			t.Logf("Iterated over more than %d keys\n", len(expected))
+			t.Fail()
+			break
+		}
+		if it.Key() != expected[i]<<56 || it.Bits() != 4 || it.Value() != expected[i] {
+			t.Logf("Expected %x/4 at %d, got %x/%d -> %x\n", expected[i]<<56, i, it.Key(), it.Bits(), it.Value())
+			t.Fail()
+		}
+		i++
+	}
+	if i != len(expected) {
+		t.Logf("Expected %d keys, got %d\n", len(expected), i)
+		t.Fail()
+	}
+	if it.Next() {
+		t.Logf("Expected exhausted iterator to stay exhausted\n")
+		t.Fail()
+	}
+}
+
+func TestIteratorEmpty(t *testing.T) {
+	it := New64[uint64]().Iterator()
+	if it.Next() {
+		t.Logf("Expected no keys in an empty tree\n")
+		t.Fail()
+	}
+}
+
+func TestIteratorSeekKey(t *testing.T) {
+	r := New64[uint64]()
+	for _, k := range []uint64{0x10, 0x20, 0x40, 0x80} {
+		r.Insert(k<<56, 4, k)
+	}
+	r.Insert(0x20<<56, 8, 0x21)
+	tests := []struct {
+		n    uint64
+		want []uint64 // the values seen after seeking
+	}{
+		{0x20 << 56, []uint64{0x20, 0x21, 0x40, 0x80}},
+		{0x30 << 56, []uint64{0x40, 0x80}},
+		{0x90 << 56, nil},
+		{0, []uint64{0x10, 0x20, 0x21, 0x40, 0x80}},
+	}
+	it := r.Iterator()
+	it.Next()
+	for _, tc := range tests {
+		it.SeekKey(tc.n)
+		var got []uint64
+		for it.Next() {
+			got = append(got, it.Value())
+		}
+		if len(got) != len(tc.want) {
+			t.Logf("Expected %x after seeking to %x, got %x\n", tc.want, tc.n, got)
+			t.Fail()
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Logf("Expected %x after seeking to %x, got %x\n", tc.want, tc.n, got)
+				t.Fail()
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package bitradix
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// A key and its value as they are marshaled to JSON.
+type jsonEntry64[T any] struct {
+	Prefix string `json:"prefix"`
+	Value  T      `json:"value"`
+}
+
+// MarshalJSON returns the keys in the tree r, in the order of Keys, as a JSON
+// array of {"prefix": ..., "value": ...} objects. The values are marshaled
+// with encoding/json. A key that holds an IPv4 prefix in its upper 32 bits, as
+// InsertPrefix stores them, is written as a CIDR like "10.0.0.0/8", any other
+// key as its hexadecimal value and bits, like "0x0a00000000000001/64".
+func (r *Radix64[T]) MarshalJSON() ([]byte, error) {
+	nodes := r.sorted()
+	entries := make([]jsonEntry64[T], len(nodes))
+	for i, x := range nodes {
+		entries[i] = jsonEntry64[T]{formatPrefix64(x.key, x.bits), x.Value}
+	}
+	return json.Marshal(entries)
+}
+
+// UnmarshalJSON replaces the keys in the tree r with the keys in data, which
+// must be in the format of MarshalJSON. r must be the root of the tree.
+func (r *Radix64[T]) UnmarshalJSON(data []byte) error {
+	var entries []jsonEntry64[T]
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+	r.Clear()
+	for _, e := range entries {
+		n, bits, err := parsePrefix64(e.Prefix)
+		if err != nil {
+			return err
+		}
+		if _, err := r.InsertE(n, bits, e.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Return n/bits as a CIDR when n holds an IPv4 prefix, or else in hexadecimal.
+func formatPrefix64(n uint64, bits int) string {
+	if bits <= 32 && uint32(n) == 0 {
+		a := netip.AddrFrom4([4]byte{byte(n >> 56), byte(n >> 48), byte(n >> 40), byte(n >> 32)})
+		return netip.PrefixFrom(a, bits).String()
+	}
+	return fmt.Sprintf("0x%016x/%d", n, bits)
+}
+
+// Return the key and bits of a prefix written by formatPrefix64.
+func parsePrefix64(s string) (uint64, int, error) {
+	if strings.Contains(s, ".") {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return 0, 0, err
+		}
+		if !p.Addr().Is4() {
+			return 0, 0, fmt.Errorf("bitradix: not an IPv4 prefix: %q", s)
+		}
+		return addrToUint64(p.Addr()), p.Bits(), nil
+	}
+	key, bits, ok := strings.Cut(s, "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("bitradix: no bits in prefix: %q", s)
+	}
+	n, err := strconv.ParseUint(key, 0, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	b, err := strconv.Atoi(bits)
+	if err != nil {
+		return 0, 0, err
+	}
+	return n, b, nil
+}
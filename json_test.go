@@ -0,0 +1,70 @@
+package bitradix
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	r := New64[string]()
+	r.Insert(0x0A00000000000000, 8, "ten")
+	r.Insert(0x0A01000000000000, 16, "ten-one")
+	r.Insert(0x0A00000000000001, 64, "host")
+	r.Insert(0, 0, "default")
+	data, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"prefix":"0.0.0.0/0","value":"default"},` +
+		`{"prefix":"10.0.0.0/8","value":"ten"},` +
+		`{"prefix":"0x0a00000000000001/64","value":"host"},` +
+		`{"prefix":"10.1.0.0/16","value":"ten-one"}]`
+	if string(data) != want {
+		t.Logf("Expected %s, got %s\n", want, data)
+		t.Fail()
+	}
+
+	r1 := New64[string]()
+	r1.Insert(0xC0A8000000000000, 16, "gone")
+	if err := json.Unmarshal(data, r1); err != nil {
+		t.Fatal(err)
+	}
+	if !r1.Equal(r, func(a, b string) bool { return a == b }) {
+		t.Logf("Expected the same tree after a round trip, got %s\n", r1)
+		t.Fail()
+	}
+}
+
+func TestMarshalJSONStruct(t *testing.T) {
+	type route struct {
+		ASN  uint32 `json:"asn"`
+		Name string `json:"name"`
+	}
+	r := New64[route]()
+	r.InsertCIDR("10.0.0.0/8", route{64512, "private"})
+	r.InsertCIDR("192.168.1.0/24", route{64513, "home"})
+	data, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r1 *Radix64[route]
+	if err := json.Unmarshal(data, &r1); err != nil {
+		t.Fatal(err)
+	}
+	if !r1.Equal(r, func(a, b route) bool { return a == b }) {
+		t.Logf("Expected the same tree after a round trip, got %s\n", r1)
+		t.Fail()
+	}
+
+	for _, bad := range []string{`[{"prefix":"10.0.0.0"}]`, `[{"prefix":"0x0a/65"}]`, `[{"prefix":"::1/128"}]`, `{}`} {
+		if err := json.Unmarshal([]byte(bad), New64[route]()); err == nil {
+			t.Logf("Expected an error for %s\n", bad)
+			t.Fail()
+		}
+	}
+	if err := json.Unmarshal([]byte(`[{"prefix":"0x0a/65"}]`), New64[route]()); !errors.Is(err, ErrBits) {
+		t.Logf("Expected ErrBits, got %v\n", err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,81 @@
+package bitradix
+
+import "container/list"
+
+// Radix64LRU wraps a Radix64 that holds at most a fixed number of keys. When
+// an Insert goes past that capacity, the least recently used key is removed.
+// Insert, Find and Get count as a use of the key they return.
+type Radix64LRU[T any] struct {
+	r        *Radix64[*list.Element]
+	lru      *list.List // of *Entry64[T], the most recently used first
+	capacity int
+}
+
+// NewLRU64 returns an empty, initialized Radix64LRU tree holding at most
+// capacity keys. It panics when capacity is smaller than one.
+func NewLRU64[T any](capacity int) *Radix64LRU[T] {
+	if capacity < 1 {
+		panic("bitradix: capacity smaller than one")
+	}
+	return &Radix64LRU[T]{r: New64[*list.Element](), lru: list.New(), capacity: capacity}
+}
+
+// Insert inserts a new value n in the tree l (possibly silently overwriting an
+// existing value), and removes the least recently used key when l holds more
+// than its capacity.
+func (l *Radix64LRU[T]) Insert(n uint64, bits int, v T) {
+	if err := checkBits64(n, bits); err != nil {
+		panic(err)
+	}
+
+	if x := l.r.exact(n, bits); x != nil {
+		x.Value.Value.(*Entry64[T]).Value = v
+		l.lru.MoveToFront(x.Value)
+		return
+	}
+	l.r.Insert(n, bits, l.lru.PushFront(&Entry64[T]{n, bits, v}))
+	if l.r.Len() > l.capacity {
+		e := l.lru.Remove(l.lru.Back()).(*Entry64[T])
+		l.r.Remove(e.Key, e.Bits)
+	}
+}
+
+// Remove removes a value from the tree l. It returns the value removed, the
+// boolean is false when nothing is found.
+func (l *Radix64LRU[T]) Remove(n uint64, bits int) (T, bool) {
+	x := l.r.Remove(n, bits)
+	if x == nil {
+		var zero T
+		return zero, false
+	}
+	return l.lru.Remove(x.Value).(*Entry64[T]).Value, true
+}
+
+// Find works like Radix64.Find, but returns the value of the node found. The
+// boolean is false when nothing is found.
+func (l *Radix64LRU[T]) Find(n uint64, bits int) (T, bool) {
+	return l.use(l.r.Find(n, bits))
+}
+
+// Get works like Radix64.Get, it returns the value of the most specific key
+// that covers n. The boolean is false when no key covers n.
+func (l *Radix64LRU[T]) Get(n uint64) (T, bool) {
+	x, _ := l.r.LongestPrefixMatch(n)
+	return l.use(x)
+}
+
+// Len returns the number of keys stored in the tree l.
+func (l *Radix64LRU[T]) Len() int {
+	return l.r.Len()
+}
+
+// Mark the key of x as the most recently used and return its value, or the
+// zero value and false when x is nil.
+func (l *Radix64LRU[T]) use(x *Radix64[*list.Element]) (T, bool) {
+	if x == nil {
+		var zero T
+		return zero, false
+	}
+	l.lru.MoveToFront(x.Value)
+	return x.Value.Value.(*Entry64[T]).Value, true
+}
@@ -0,0 +1,47 @@
+package bitradix
+
+import "testing"
+
+func TestRadix64LRU(t *testing.T) {
+	l := NewLRU64[string](3)
+	l.Insert(0x0A00000000000000, 8, "ten")
+	l.Insert(0x0B00000000000000, 8, "eleven")
+	l.Insert(0x0C00000000000000, 8, "twelve")
+	// A recent Get protects 10.0.0.0/8, so 11.0.0.0/8 is the oldest.
+	if v, ok := l.Get(0x0A01020300000000); !ok || v != "ten" {
+		t.Logf("Expected %q, got %q\n", "ten", v)
+		t.Fail()
+	}
+	l.Insert(0x0D00000000000000, 8, "thirteen")
+	if l.Len() != 3 {
+		t.Logf("Expected %d keys, got %d\n", 3, l.Len())
+		t.Fail()
+	}
+	if _, ok := l.Find(0x0B00000000000000, 8); ok {
+		t.Logf("Expected 11.0.0.0/8 to be evicted\n")
+		t.Fail()
+	}
+	for _, n := range []uint64{0x0A00000000000000, 0x0C00000000000000, 0x0D00000000000000} {
+		if _, ok := l.Find(n, 8); !ok {
+			t.Logf("Expected %016x/8 to be kept\n", n)
+			t.Fail()
+		}
+	}
+
+	// Overwriting counts as a use, 10.0.0.0/8 is now the oldest.
+	l.Insert(0x0C00000000000000, 8, "TWELVE")
+	l.Insert(0x0D00000000000000, 8, "THIRTEEN")
+	l.Insert(0x0E00000000000000, 8, "fourteen")
+	if _, ok := l.Find(0x0A00000000000000, 8); ok {
+		t.Logf("Expected 10.0.0.0/8 to be evicted\n")
+		t.Fail()
+	}
+	if v, ok := l.Remove(0x0C00000000000000, 8); !ok || v != "TWELVE" || l.Len() != 2 {
+		t.Logf("Expected to remove %q, got %q\n", "TWELVE", v)
+		t.Fail()
+	}
+	if _, ok := l.Remove(0x0C00000000000000, 8); ok {
+		t.Logf("Expected nothing to remove\n")
+		t.Fail()
+	}
+}
@@ -0,0 +1,56 @@
+package bitradix
+
+import "math/bits"
+
+// Radix64LSB wraps a Radix64 that indexes its keys from bit 0 upward, the first
+// bits bits of a key are its lowest bits. This is useful for keys whose
+// significant bits are the low order ones, such as reversed identifiers.
+//
+// Keys are stored bit reversed in the wrapped tree, so Insert, Find and Remove
+// all see the same ordering. The methods return values instead of nodes, as the
+// key of a node would be reversed.
+type Radix64LSB[T any] struct {
+	r *Radix64[T]
+}
+
+// New64LSBFirst returns an empty, initialized Radix64LSB tree.
+func New64LSBFirst[T any]() *Radix64LSB[T] {
+	return &Radix64LSB[T]{r: New64[T]()}
+}
+
+// Insert inserts the value v under the key n, where the lowest bits bits of n
+// are significant, possibly overwriting an existing value.
+func (l *Radix64LSB[T]) Insert(n uint64, bits int, v T) {
+	l.r.Insert(reverse64(n), bits, v)
+}
+
+// Remove removes the key n/bits from the tree l. It returns the value removed,
+// the boolean is false when nothing is found.
+func (l *Radix64LSB[T]) Remove(n uint64, bits int) (T, bool) {
+	return value64(l.r.Remove(reverse64(n), bits))
+}
+
+// Find works like Radix64.Find, with the lowest bits bits of n significant,
+// but returns the value of the node found. The boolean is false when nothing
+// is found.
+func (l *Radix64LSB[T]) Find(n uint64, bits int) (T, bool) {
+	return value64(l.r.Find(reverse64(n), bits))
+}
+
+// LongestPrefixMatch returns the value of the key with the most significant
+// bits that matches the low order bits of n. The boolean is false when no key
+// matches n.
+func (l *Radix64LSB[T]) LongestPrefixMatch(n uint64) (T, bool) {
+	x, _ := l.r.LongestPrefixMatch(reverse64(n))
+	return value64(x)
+}
+
+// Len returns the number of keys stored in the tree l.
+func (l *Radix64LSB[T]) Len() int {
+	return l.r.Len()
+}
+
+// Return n with the order of its bits reversed.
+func reverse64(n uint64) uint64 {
+	return bits.Reverse64(n)
+}
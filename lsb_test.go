@@ -0,0 +1,44 @@
+package bitradix
+
+import "testing"
+
+func TestRadix64LSB(t *testing.T) {
+	l := New64LSBFirst[string]()
+	// These keys only differ in their low bits.
+	l.Insert(0x01, 4, "one")
+	l.Insert(0x02, 4, "two")
+	l.Insert(0x12, 8, "one-two")
+	if l.Len() != 3 {
+		t.Logf("Expected %d keys, got %d\n", 3, l.Len())
+		t.Fail()
+	}
+	tests := map[uint64]string{
+		0xFFFFFFFF00000001: "one",
+		0x0000000000000022: "two",
+		0xABCDEF0000000012: "one-two",
+		0x0000000000000003: "",
+	}
+	for n, want := range tests {
+		v, ok := l.LongestPrefixMatch(n)
+		if v != want || ok != (want != "") {
+			t.Logf("Expected %q, got %q for %016x\n", want, v, n)
+			t.Fail()
+		}
+	}
+	if v, ok := l.Find(0x12, 8); !ok || v != "one-two" {
+		t.Logf("Expected %q, got %q\n", "one-two", v)
+		t.Fail()
+	}
+	if v, ok := l.Remove(0x02, 4); !ok || v != "two" {
+		t.Logf("Expected to remove %q, got %q\n", "two", v)
+		t.Fail()
+	}
+	if v, ok := l.Find(0x12, 8); !ok || v != "one-two" {
+		t.Logf("Expected %q after removal, got %q\n", "one-two", v)
+		t.Fail()
+	}
+	if _, ok := l.LongestPrefixMatch(0x22); ok {
+		t.Logf("Expected no match after removal\n")
+		t.Fail()
+	}
+}
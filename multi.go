@@ -0,0 +1,60 @@
+package bitradix
+
+import "slices"
+
+// Radix64Multi wraps a Radix64 that stores a list of values under each key.
+// Values are appended with Add, and can be removed one at a time or all at
+// once. A key is removed from the tree when its last value is removed.
+type Radix64Multi[T comparable] struct {
+	r *Radix64[[]T]
+}
+
+// New64Multi returns an empty, initialized Radix64Multi tree.
+func New64Multi[T comparable]() *Radix64Multi[T] {
+	return &Radix64Multi[T]{r: New64[[]T]()}
+}
+
+// Add appends v to the values stored under the key n/bits.
+func (m *Radix64Multi[T]) Add(n uint64, bits int, v T) {
+	m.r.Update(n, bits, func(old []T, _ bool) []T { return append(old, v) })
+}
+
+// Find works like Radix64.Find, but returns a copy of the values of the node
+// found. It returns nil when nothing is found.
+func (m *Radix64Multi[T]) Find(n uint64, bits int) []T {
+	x := m.r.Find(n, bits)
+	if x == nil {
+		return nil
+	}
+	return slices.Clone(x.Value)
+}
+
+// Remove removes the first value equal to v from the key n/bits, and reports
+// whether there was one.
+func (m *Radix64Multi[T]) Remove(n uint64, bits int, v T) bool {
+	x := m.r.exact(n, bits)
+	if x == nil {
+		return false
+	}
+	i := slices.Index(x.Value, v)
+	if i < 0 {
+		return false
+	}
+	x.Value = slices.Delete(x.Value, i, i+1)
+	if len(x.Value) == 0 {
+		m.r.Remove(n, bits)
+	}
+	return true
+}
+
+// RemoveAll removes the key n/bits with all its values, and returns those
+// values. It returns nil when the key is not found.
+func (m *Radix64Multi[T]) RemoveAll(n uint64, bits int) []T {
+	v, _ := m.r.Delete(n, bits)
+	return v
+}
+
+// Len returns the number of keys stored in the tree m.
+func (m *Radix64Multi[T]) Len() int {
+	return m.r.Len()
+}
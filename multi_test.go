@@ -0,0 +1,47 @@
+package bitradix
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRadix64Multi(t *testing.T) {
+	m := New64Multi[string]()
+	m.Add(0x0A00000000000000, 8, "allow")
+	m.Add(0x0A00000000000000, 8, "log")
+	m.Add(0x0A00000000000000, 8, "allow")
+	m.Add(0x0A01000000000000, 16, "deny")
+	if m.Len() != 2 {
+		t.Logf("Expected %d keys, got %d\n", 2, m.Len())
+		t.Fail()
+	}
+	if v := m.Find(0x0A00000000000000, 8); !slices.Equal(v, []string{"allow", "log", "allow"}) {
+		t.Logf("Expected all values, got %v\n", v)
+		t.Fail()
+	}
+	if v := m.Find(0x0A01020000000000, 24); !slices.Equal(v, []string{"deny"}) {
+		t.Logf("Expected the values of the covering key, got %v\n", v)
+		t.Fail()
+	}
+
+	if !m.Remove(0x0A00000000000000, 8, "allow") || m.Remove(0x0A00000000000000, 8, "deny") {
+		t.Logf("Expected to remove only values that are present\n")
+		t.Fail()
+	}
+	if v := m.Find(0x0A00000000000000, 8); !slices.Equal(v, []string{"log", "allow"}) {
+		t.Logf("Expected one value less, got %v\n", v)
+		t.Fail()
+	}
+	if !m.Remove(0x0A01000000000000, 16, "deny") || m.Len() != 1 {
+		t.Logf("Expected the key to go with its last value\n")
+		t.Fail()
+	}
+	if v := m.RemoveAll(0x0A00000000000000, 8); !slices.Equal(v, []string{"log", "allow"}) || m.Len() != 0 {
+		t.Logf("Expected to remove all values, got %v\n", v)
+		t.Fail()
+	}
+	if v := m.RemoveAll(0x0A00000000000000, 8); v != nil {
+		t.Logf("Expected nothing to remove, got %v\n", v)
+		t.Fail()
+	}
+}
@@ -0,0 +1,111 @@
+package bitradix
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/netip"
+	"slices"
+)
+
+// InsertPrefix inserts v under the IPv4 prefix p. The network bits of p are
+// left-aligned into the upper 32 bits of the key. IPv4-mapped IPv6 prefixes are
+// unmapped first, any other prefix makes InsertPrefix panic. r must be the root
+// of the tree.
+func (r *Radix64[T]) InsertPrefix(p netip.Prefix, v T) *Radix64[T] {
+	n, bits, ok := prefixToUint64(p)
+	if !ok {
+		panic("bitradix: not an IPv4 prefix")
+	}
+	return r.Insert(n, bits, v)
+}
+
+// InsertCIDR parses cidr as an IPv4 prefix, like "10.0.0.0/8", and inserts v
+// under it as InsertPrefix does. Host bits set in cidr are ignored. An error is
+// returned when cidr is malformed or not an IPv4 prefix. r must be the root of
+// the tree.
+func (r *Radix64[T]) InsertCIDR(cidr string, v T) (*Radix64[T], error) {
+	p, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return nil, err
+	}
+	n, bits, ok := prefixToUint64(p)
+	if !ok {
+		return nil, fmt.Errorf("bitradix: not an IPv4 prefix: %q", cidr)
+	}
+	return r.InsertE(n, bits, v)
+}
+
+// FromCIDRMap64 returns a new tree holding the values of m under their keys,
+// which are parsed as by InsertCIDR. The keys are inserted in sorted order, so
+// when several are malformed the error is always about the same one. The error
+// names the offending key, and no tree is returned then.
+func FromCIDRMap64[T any](m map[string]T) (*Radix64[T], error) {
+	keys := make([]string, 0, len(m))
+	for cidr := range m {
+		keys = append(keys, cidr)
+	}
+	slices.Sort(keys)
+	r := New64[T]()
+	for _, cidr := range keys {
+		if _, err := r.InsertCIDR(cidr, m[cidr]); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// LookupAddr returns the node holding the most specific prefix that covers the
+// IPv4 address a. The boolean is false when no prefix covers a, or when a is
+// not an IPv4 (or IPv4-mapped IPv6) address.
+func (r *Radix64[T]) LookupAddr(a netip.Addr) (*Radix64[T], bool) {
+	a = a.Unmap()
+	if !a.Is4() {
+		return nil, false
+	}
+	return r.LongestPrefixMatch(addrToUint64(a))
+}
+
+// DumpCIDR writes the keys in the tree r to w, in the order of Keys, one per
+// line as a CIDR followed by the value. When v4 is true a key is read as an
+// IPv4 prefix in its upper 32 bits, as InsertPrefix stores them, a key that
+// does not fit is written in hexadecimal like MarshalJSON does. When v4 is
+// false a key is read as the upper 64 bits of an IPv6 prefix. It stops at the
+// first error from w and returns it.
+func (r *Radix64[T]) DumpCIDR(w io.Writer, v4 bool) error {
+	for _, x := range r.sorted() {
+		var p string
+		if v4 {
+			p = formatPrefix64(x.key, x.bits)
+		} else {
+			var b [16]byte
+			binary.BigEndian.PutUint64(b[:8], x.key)
+			p = netip.PrefixFrom(netip.AddrFrom16(b), x.bits).String()
+		}
+		if _, err := fmt.Fprintf(w, "%s %v\n", p, x.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Return the key and number of bits for the IPv4 prefix p.
+func prefixToUint64(p netip.Prefix) (uint64, int, bool) {
+	a := p.Addr()
+	bits := p.Bits()
+	if a.Is4In6() {
+		a = a.Unmap()
+		bits -= 96
+	}
+	if !a.Is4() || bits < 0 {
+		return 0, 0, false
+	}
+	p, _ = a.Prefix(bits)
+	return addrToUint64(p.Addr()), bits, true
+}
+
+// Return the IPv4 address a left-aligned in an uint64.
+func addrToUint64(a netip.Addr) uint64 {
+	b := a.As4()
+	return uint64(b[0])<<56 | uint64(b[1])<<48 | uint64(b[2])<<40 | uint64(b[3])<<32
+}
@@ -0,0 +1,168 @@
+package bitradix
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestInsertPrefix(t *testing.T) {
+	r := New64[string]()
+	for _, p := range []string{"10.0.0.0/8", "10.20.0.0/14", "10.21.0.0/16", "192.168.2.0/24", "8.8.8.8/32", "::ffff:172.16.0.0/108"} {
+		r.InsertPrefix(netip.MustParsePrefix(p), p)
+	}
+	r.Do(func(r1 *Radix64[string], i int) { t.Logf("(%2d): %064b/%d -> %s\n", i, r1.key, r1.bits, r1.Value) })
+
+	tests := map[string]string{
+		"10.20.1.2":        "10.20.0.0/14",
+		"10.22.1.2":        "10.20.0.0/14",
+		"10.19.0.1":        "10.0.0.0/8",
+		"10.21.0.1":        "10.21.0.0/16",
+		"192.168.2.3":      "192.168.2.0/24",
+		"::ffff:10.21.0.1": "10.21.0.0/16",
+		"172.16.4.1":       "::ffff:172.16.0.0/108",
+		"8.8.8.8":          "8.8.8.8/32",
+		"8.8.8.9":          "",
+		"230.0.0.1":        "",
+		"2001:db8::1":      "",
+	}
+	for addr, prefix := range tests {
+		x, ok := r.LookupAddr(netip.MustParseAddr(addr))
+		if ok != (prefix != "") {
+			t.Logf("Expected match %v, got %v for %s\n", prefix != "", ok, addr)
+			t.Fail()
+			continue
+		}
+		if ok && x.Value != prefix {
+			t.Logf("Expected %s, got %s for %s\n", prefix, x.Value, addr)
+			t.Fail()
+		}
+	}
+}
+
+func TestInsertPrefixHostBits(t *testing.T) {
+	r := New64[int]()
+	r.InsertPrefix(netip.MustParsePrefix("10.1.2.3/8"), 8)
+	if x := r.Find(0x0A00000000000000, 8); x == nil || x.Key() != 0x0A00000000000000 {
+		t.Logf("Expected host bits to be cleared\n")
+		t.Fail()
+	}
+}
+
+func TestInsertPrefixIPv6(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Logf("Expected panic for IPv6 prefix\n")
+			t.Fail()
+		}
+	}()
+	New64[int]().InsertPrefix(netip.MustParsePrefix("2001:db8::/32"), 1)
+}
+
+func TestInsertCIDR(t *testing.T) {
+	r := New64[string]()
+	for _, cidr := range []string{"10.0.0.0/8", "10.1.2.0/24", "8.8.8.8/32"} {
+		x, err := r.InsertCIDR(cidr, cidr)
+		if err != nil || x.Value != cidr {
+			t.Logf("Expected %s to be inserted, got %v\n", cidr, err)
+			t.Fail()
+		}
+	}
+	if x := r.Find(0x0808080800000000, 32); x == nil || x.Value != "8.8.8.8/32" {
+		t.Logf("Expected to find the host route 8.8.8.8/32\n")
+		t.Fail()
+	}
+	if x := r.Find(0x0A01020000000000, 24); x == nil || x.Value != "10.1.2.0/24" {
+		t.Logf("Expected to find 10.1.2.0/24\n")
+		t.Fail()
+	}
+	if x, err := r.InsertCIDR("0.0.0.0/0", "default"); err != nil || x.Key() != 0 || x.Bits() != 0 {
+		t.Logf("Expected the default route to be accepted, got %v\n", err)
+		t.Fail()
+	}
+	if x, ok := r.LookupAddr(netip.MustParseAddr("192.0.2.1")); !ok || x.Value != "default" {
+		t.Logf("Expected to find the default route\n")
+		t.Fail()
+	}
+	for _, cidr := range []string{"", "10.0.0.0", "10.0.0.0/33", "300.0.0.0/8", "2001:db8::/32"} {
+		if _, err := r.InsertCIDR(cidr, cidr); err == nil {
+			t.Logf("Expected an error for %q\n", cidr)
+			t.Fail()
+		}
+	}
+	if r.Len() != 4 {
+		t.Logf("Expected 4 keys, got %d\n", r.Len())
+		t.Fail()
+	}
+}
+
+func TestFromCIDRMap64(t *testing.T) {
+	r, err := FromCIDRMap64(map[string]int{"10.0.0.0/8": 8, "10.1.0.0/16": 16, "0.0.0.0/0": 0})
+	if err != nil || r.Len() != 3 {
+		t.Fatalf("Expected 3 keys, got %v\n", err)
+	}
+	if x, ok := r.LookupAddr(netip.MustParseAddr("10.1.2.3")); !ok || x.Value != 16 {
+		t.Logf("Expected 10.1.0.0/16 to match\n")
+		t.Fail()
+	}
+
+	if r, err := FromCIDRMap64(map[string]int{}); err != nil || r.Len() != 0 {
+		t.Logf("Expected an empty tree, got %v\n", err)
+		t.Fail()
+	}
+
+	r, err = FromCIDRMap64(map[string]int{"10.0.0.0/8": 8, "10.0.0.0/33": 33, "192.168.0.0/16": 16})
+	if err == nil || r != nil || !strings.Contains(err.Error(), "10.0.0.0/33") {
+		t.Logf("Expected an error about 10.0.0.0/33, got %v\n", err)
+		t.Fail()
+	}
+}
+
+func TestDumpCIDR(t *testing.T) {
+	r := New64[string]()
+	r.Insert(0x0A14000000000000, 14, "a")
+	r.Insert(0xC0A8020000000000, 24, "b")
+	r.Insert(0x0A00000000000001, 64, "c")
+
+	var sb strings.Builder
+	if err := r.DumpCIDR(&sb, true); err != nil {
+		t.Fatalf("Expected no error, got %v\n", err)
+	}
+	expected := "0x0a00000000000001/64 c\n10.20.0.0/14 a\n192.168.2.0/24 b\n"
+	if sb.String() != expected {
+		t.Logf("Expected %q, got %q\n", expected, sb.String())
+		t.Fail()
+	}
+
+	sb.Reset()
+	if err := r.DumpCIDR(&sb, false); err != nil {
+		t.Fatalf("Expected no error, got %v\n", err)
+	}
+	expected = "a00:0:0:1::/64 c\na14::/14 a\nc0a8:200::/24 b\n"
+	if sb.String() != expected {
+		t.Logf("Expected %q, got %q\n", expected, sb.String())
+		t.Fail()
+	}
+
+	w := &failWriter{n: 1}
+	if err := r.DumpCIDR(w, true); err != errFailWriter || w.writes != 2 {
+		t.Logf("Expected to stop at the first write error, got %v after %d writes\n", err, w.writes)
+		t.Fail()
+	}
+}
+
+var errFailWriter = errors.New("write failed")
+
+// failWriter fails every write after the first n.
+type failWriter struct {
+	n, writes int
+}
+
+func (w *failWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if w.writes > w.n {
+		return 0, errFailWriter
+	}
+	return len(p), nil
+}
@@ -0,0 +1,44 @@
+package bitradix
+
+// Prefix64 is a key with its number of significant bits, with all bits after
+// the significant ones set to zero. The zero value is the prefix 0/0. Use
+// NewPrefix64 to create one.
+type Prefix64 struct {
+	key  uint64
+	bits int
+}
+
+// NewPrefix64 returns the prefix key/bits with the bits of key after the first
+// bits bits set to zero, see NormalizeKey64. An error wrapping ErrBits is
+// returned when bits is not in [0, 64].
+func NewPrefix64(key uint64, bits int) (Prefix64, error) {
+	if err := checkBits64(key, bits); err != nil {
+		return Prefix64{}, err
+	}
+	return Prefix64{key: NormalizeKey64(key, bits), bits: bits}, nil
+}
+
+// Key returns the key of the prefix p.
+func (p Prefix64) Key() uint64 {
+	return p.key
+}
+
+// Bits returns the number of significant bits of the prefix p.
+func (p Prefix64) Bits() int {
+	return p.bits
+}
+
+// InsertP works like Insert, but takes the key and bits from p.
+func (r *Radix64[T]) InsertP(p Prefix64, v T) *Radix64[T] {
+	return r.Insert(p.key, p.bits, v)
+}
+
+// FindP works like Find, but takes the key and bits from p.
+func (r *Radix64[T]) FindP(p Prefix64) *Radix64[T] {
+	return r.Find(p.key, p.bits)
+}
+
+// RemoveP works like Remove, but takes the key and bits from p.
+func (r *Radix64[T]) RemoveP(p Prefix64) *Radix64[T] {
+	return r.Remove(p.key, p.bits)
+}
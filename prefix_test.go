@@ -0,0 +1,53 @@
+package bitradix
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNewPrefix64(t *testing.T) {
+	tests := []struct {
+		key  uint64
+		bits int
+		want uint64
+		err  error
+	}{
+		{0x0A000005FFFFFFFF, 24, 0x0A00000000000000, nil},
+		{0x0A00000000000000, 8, 0x0A00000000000000, nil},
+		{0xFFFFFFFFFFFFFFFF, 64, 0xFFFFFFFFFFFFFFFF, nil},
+		{0xFFFFFFFFFFFFFFFF, 0, 0, nil},
+		{0x0A00000000000000, 65, 0, ErrBits},
+		{0x0A00000000000000, -1, 0, ErrBits},
+	}
+	for _, tc := range tests {
+		p, err := NewPrefix64(tc.key, tc.bits)
+		if !errors.Is(err, tc.err) {
+			t.Logf("Expected error %v for %016x/%d, got %v\n", tc.err, tc.key, tc.bits, err)
+			t.Fail()
+			continue
+		}
+		if err == nil && (p.Key() != tc.want || p.Bits() != tc.bits) {
+			t.Logf("Expected %016x/%d, got %016x/%d\n", tc.want, tc.bits, p.Key(), p.Bits())
+			t.Fail()
+		}
+	}
+}
+
+func TestPrefix64RoundTrip(t *testing.T) {
+	r := New64[string]()
+	p, _ := NewPrefix64(0x0A010203FFFFFFFF, 16)
+	q, _ := NewPrefix64(0x0A01000000000000, 16)
+	r.InsertP(p, "ten-one")
+	if x := r.FindP(q); x == nil || x.Value != "ten-one" || x.Key() != 0x0A01000000000000 {
+		t.Logf("Expected to find %q under %016x/16\n", "ten-one", q.Key())
+		t.Fail()
+	}
+	if x := r.RemoveP(q); x == nil || x.Value != "ten-one" {
+		t.Logf("Expected to remove %q\n", "ten-one")
+		t.Fail()
+	}
+	if r.FindP(p) != nil || r.Len() != 0 {
+		t.Logf("Expected an empty tree after removal\n")
+		t.Fail()
+	}
+}
@@ -1,11 +1,11 @@
 package bitradix
 
-type node32[T any] struct {
-	*Radix32[T]
-	branch int // -1 root, 0 left branch, 1 right branch
+type node[K Unsigned, T any] struct {
+	*Radix[K, T]
+	branch int
 }
 
-type queue32[T any] []*node32[T]
+type queue[K Unsigned, T any] []*node[K, T]
 
 type node64[T any] struct {
 	*Radix64[T]
@@ -14,19 +14,18 @@ type node64[T any] struct {
 
 type queue64[T any] []*node64[T]
 
-// Push adds a node32 to the queue.
-func (q *queue32[T]) Push(n *node32[T]) {
+func (q *queue64[T]) Push(n *node64[T]) {
 	*q = append(*q, n)
 }
 
-// Pop removes and returns a node from the queue in first to last order.
-func (q *queue32[T]) Pop() *node32[T] {
+func (q *queue64[T]) Pop() *node64[T] {
 	lq := len(*q)
 	if lq == 0 {
 		return nil
 	}
 
 	n := (*q)[0]
+	(*q)[0] = nil // do not keep the popped node reachable through the backing array
 	switch lq {
 	case 1:
 		*q = (*q)[:0]
@@ -37,17 +36,78 @@ func (q *queue32[T]) Pop() *node32[T] {
 	return n
 }
 
-func (q *queue64[T]) Push(n *node64[T]) {
+type node128[T any] struct {
+	*Radix128[T]
+	branch int
+}
+
+type queue128[T any] []*node128[T]
+
+func (q *queue128[T]) Push(n *node128[T]) {
 	*q = append(*q, n)
 }
 
-func (q *queue64[T]) Pop() *node64[T] {
+func (q *queue128[T]) Pop() *node128[T] {
+	lq := len(*q)
+	if lq == 0 {
+		return nil
+	}
+
+	n := (*q)[0]
+	(*q)[0] = nil // do not keep the popped node reachable through the backing array
+	switch lq {
+	case 1:
+		*q = (*q)[:0]
+	default:
+		*q = (*q)[1:lq]
+	}
+
+	return n
+}
+
+type nodeBytes[T any] struct {
+	*RadixBytes[T]
+	branch int
+}
+
+type queueBytes[T any] []*nodeBytes[T]
+
+func (q *queueBytes[T]) Push(n *nodeBytes[T]) {
+	*q = append(*q, n)
+}
+
+func (q *queueBytes[T]) Pop() *nodeBytes[T] {
+	lq := len(*q)
+	if lq == 0 {
+		return nil
+	}
+
+	n := (*q)[0]
+	(*q)[0] = nil // do not keep the popped node reachable through the backing array
+	switch lq {
+	case 1:
+		*q = (*q)[:0]
+	default:
+		*q = (*q)[1:lq]
+	}
+
+	return n
+}
+
+// Push adds a node to the queue.
+func (q *queue[K, T]) Push(n *node[K, T]) {
+	*q = append(*q, n)
+}
+
+// Pop removes and returns a node from the queue in first to last order.
+func (q *queue[K, T]) Pop() *node[K, T] {
 	lq := len(*q)
 	if lq == 0 {
 		return nil
 	}
 
 	n := (*q)[0]
+	(*q)[0] = nil // do not keep the popped node reachable through the backing array
 	switch lq {
 	case 1:
 		*q = (*q)[:0]
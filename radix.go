@@ -0,0 +1,536 @@
+package bitradix
+
+import (
+	"fmt"
+	"math/bits"
+	"sync"
+)
+
+// Unsigned is the set of unsigned integer types that can be used as the key of
+// a Radix tree.
+type Unsigned interface {
+	~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// Radix implements a radix tree with an unsigned integer of type K as its key,
+// the width of the key is taken from K. A key with zero bits is the default
+// route, which covers every key. Radix32 and Radix64 are Radix trees with an
+// uint32 and an uint64 key, Radix64 adds the methods that only make sense for
+// its key, such as InsertPrefix.
+type Radix[K Unsigned, T any] struct {
+	branch [2]*Radix[K, T] // branch[0] is left branch for 0, and branch[1] the right for 1
+	parent *Radix[K, T]
+	key    K            // the key under which this value is stored
+	bits   int          // the number of significant bits, if 0 the key has not been set.
+	count  int          // the number of keys stored in the tree, only maintained in the root.
+	dflt   *Radix[K, T] // the default route, which has zero bits, only set in the root.
+	pool   *sync.Pool   // the pool nodes are taken from and returned to, only set in the root.
+	Value  T            // The value stored.
+}
+
+// New returns an empty, initialized Radix tree.
+func New[K Unsigned, T any]() *Radix[K, T] {
+	r := &Radix[K, T]{}
+	// It gets two branches by default
+	r.branch[0] = r.new()
+	r.branch[1] = r.new()
+	return r
+}
+
+// Key returns the key under which this node is stored.
+func (r *Radix[K, _]) Key() K {
+	return r.key
+}
+
+// Bits returns the number of significant bits for the key.
+// A value of zero indicates a key that has not been set.
+func (r *Radix[K, _]) Bits() int {
+	return r.bits
+}
+
+// Leaf returns true is r is an leaf node, when false is returned
+// the node is a non-leaf node.
+func (r *Radix[K, _]) Leaf() bool {
+	return r.branch[0] == nil && r.branch[1] == nil
+}
+
+// Insert inserts a new value n in the tree r (possibly silently overwriting an existing value).
+// It returns the inserted node, r must be the root of the tree. When bits is
+// zero v becomes the default route. Insert panics when bits is not in the
+// range [0, width of K], see InsertE.
+func (r *Radix[K, T]) Insert(n K, bits int, v T) *Radix[K, T] {
+	x, err := r.InsertE(n, bits, v)
+	if err != nil {
+		panic(err)
+	}
+	return x
+}
+
+// InsertE works like Insert, but returns an error instead of panicking when
+// bits is not in the range [0, width of K].
+func (r *Radix[K, T]) InsertE(n K, bits int, v T) (*Radix[K, T], error) {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+	if err := checkBits(n, bits); err != nil {
+		return nil, err
+	}
+
+	if x := r.exact(n, bits); x != nil { // equal keys, overwrite
+		x.set(n, bits, v)
+		return x, nil
+	}
+	return r.add(n, bits, v), nil
+}
+
+// Remove removes a value from the tree r. It returns the node removed, or nil
+// when nothing is found, r must be the root of the tree. Nothing is found when
+// bits is not in the range [0, width of K].
+func (r *Radix[K, T]) Remove(n K, bits int) *Radix[K, T] {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+	if checkBits(n, bits) != nil {
+		return nil
+	}
+	if bits == 0 {
+		return r.removeDefault()
+	}
+	return r.remove(n, bits, width[K]()-1)
+}
+
+// Find searches the tree for the key n, where the first bits bits of n
+// are significant. It returns the node found or a node with a common prefix,
+// which is the default route when nothing else covers n/bits. It returns nil
+// when nothing can be found, or when bits is not in the range [0, width of K].
+func (r *Radix[K, T]) Find(n K, bits int) *Radix[K, T] {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+	if checkBits(n, bits) != nil {
+		return nil
+	}
+	if bits == 0 {
+		return r.dflt
+	}
+	if x := r.find(n, bits, width[K]()-1, nil); x != nil {
+		return x
+	}
+	return r.dflt
+}
+
+// Len returns the number of keys stored in the tree r, r must be the root
+// of the tree.
+func (r *Radix[K, T]) Len() int {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	return r.count
+}
+
+// Do traverses the tree r in breadth-first order. For each visited node,
+// the function f is called with the current node, and the branch taken
+// (0 for the zero, 1 for the one branch, -1 is used for the root node).
+// The default route is not visited.
+func (r *Radix[K, T]) Do(f func(*Radix[K, T], int)) {
+	q := make(queue[K, T], 0)
+
+	q.Push(&node[K, T]{r, -1})
+	x := q.Pop()
+	for x != nil {
+		f(x.Radix, x.branch)
+		for i, b := range x.Radix.branch {
+			if b != nil {
+				q.Push(&node[K, T]{b, i})
+			}
+		}
+		x = q.Pop()
+	}
+}
+
+// Insert the key n/bits in the tree r, when bits is zero v becomes the default
+// route. r must be the root of the tree.
+func (r *Radix[K, T]) add(n K, bits int, v T) *Radix[K, T] {
+	if bits == 0 {
+		if r.dflt == nil {
+			r.dflt = &Radix[K, T]{parent: r}
+			r.count++
+		}
+		r.dflt.Value = v
+		return r.dflt
+	}
+	return r.insert(n, bits, v, width[K]()-1)
+}
+
+// Remove the default route from the tree r and return it, or nil when there
+// is none. r must be the root of the tree.
+func (r *Radix[K, T]) removeDefault() *Radix[K, T] {
+	x := r.dflt
+	if x == nil {
+		return nil
+	}
+	r.dflt = nil
+	r.count--
+	x.parent = nil
+	return x
+}
+
+// Implement insert. A node at depth d, which branches on bit width-1-d, only
+// holds keys with at least d significant bits, so every key covering n is
+// found along the path of n. A key is never pushed below the node where its
+// significant bits end, and it takes the place of a longer key it passes, so
+// the keys along a path never get shorter.
+func (r *Radix[K, T]) insert(n K, bits int, v T, bit int) *Radix[K, T] {
+	switch r.Leaf() {
+	case false: // Non-leaf node, one or two branches, possibly a key
+		if bit < 0 {
+			panic(fmt.Sprintf("bitradix: bit index smaller than zero: key %0*b, bits %d, bit %d", width[K](), n, bits, bit))
+		}
+		// I should be put here, as I can not go further down, or as this node
+		// is the one with my bits in the path to it
+		if bits == width[K]()-1-bit || bits == width[K]()-bit && r.bits == 0 || r.bits > bits {
+			if r.bits > 0 {
+				// move the current key down
+				n1, b1, v1 := r.key, r.bits, r.Value
+				r.set(n, bits, v)
+				bcur := bitK(n1, bit)
+				if r.branch[bcur] == nil {
+					r.branch[bcur] = r.new()
+				}
+				r.branch[bcur].insert(n1, b1, v1, bit-1)
+				return r
+			}
+			r.set(n, bits, v)
+			return r
+		}
+		bnew := bitK(n, bit)
+		if r.branch[bnew] == nil {
+			r.branch[bnew] = r.new()
+		}
+		return r.branch[bnew].insert(n, bits, v, bit-1)
+	case true: // External node, (optional) key, no branches
+		if r.bits == 0 || r.key == n && r.bits == bits { // nothing here yet, put something in, or equal keys
+			r.set(n, bits, v)
+			return r
+		}
+		if bit < 0 {
+			panic(fmt.Sprintf("bitradix: bit index smaller than zero: key %0*b, bits %d, bit %d", width[K](), n, bits, bit))
+		}
+		if bits < r.bits {
+			// the shortest key stays here, the current key moves down
+			n1, b1, v1 := r.key, r.bits, r.Value
+			r.set(n, bits, v)
+			bcur := bitK(n1, bit)
+			r.branch[bcur] = r.new()
+			r.branch[bcur].insert(n1, b1, v1, bit-1)
+			return r
+		}
+		bnew := bitK(n, bit)
+		r.branch[bnew] = r.new()
+		return r.branch[bnew].insert(n, bits, v, bit-1)
+	}
+	panic("bitradix: not reached")
+}
+
+// Walk the tree searching for n, keep the last node that has a key in tow.
+// This is the node we should retreat to when we find and delete our node.
+func (r *Radix[K, T]) remove(n K, bits, bit int) *Radix[K, T] {
+	if r.bits > 0 && r.bits == bits {
+		// possible hit
+		mask := ^K(0) << (width[K]() - r.bits)
+		if r.key&mask == n&mask {
+			// save r in r1
+			r1 := &Radix[K, T]{
+				[2]*Radix[K, T]{nil, nil},
+				nil,
+				r.key,
+				r.bits,
+				0,
+				nil,
+				nil,
+				r.Value,
+			}
+			r.prune(true, nil)
+			return r1
+		}
+	}
+	k := bitK(n, bit)
+	if r.Leaf() || r.branch[k] == nil { // dead end
+		return nil
+	}
+	return r.branch[bitK(n, bit)].remove(n, bits, bit-1)
+}
+
+// Set the parent of r's branches to r, after they have been moved.
+func (r *Radix[K, T]) adopt() {
+	for _, b := range r.branch {
+		if b != nil {
+			b.parent = r
+		}
+	}
+}
+
+// Prune the tree, when b is true the current node is deleted. When gone is not
+// nil it is called with every node that loses its key, before it is changed.
+func (r *Radix[K, T]) prune(b bool, gone func(*Radix[K, T])) {
+	if b {
+		if gone != nil {
+			gone(r)
+		}
+		r.clear()
+		if !r.Leaf() {
+			// our subtree stays, we may become redundant
+			r.prune(false, gone)
+			return
+		}
+		if r.parent == nil {
+			return
+		}
+		root := r.root()
+		// we are a node, we have a parent, so the parent is a non-leaf node
+		parent := r.parent
+		if parent.branch[0] == r {
+			// kill that branch
+			parent.branch[0] = nil
+		}
+		if parent.branch[1] == r {
+			parent.branch[1] = nil
+		}
+		if root.pool != nil {
+			r.free(root.pool)
+		}
+		parent.prune(false, gone)
+		return
+	}
+	if r == nil {
+		return
+	}
+	if r.bits != 0 {
+		// fun stops
+		return
+	}
+	// Does I have one or two childeren, if one, move my self up one node
+	// Also the child must be a leaf node!
+	b0 := r.branch[0]
+	b1 := r.branch[1]
+	if b0 != nil && b1 != nil {
+		// two branches, we cannot replace ourselves with a child
+		return
+	}
+	if b0 != nil {
+		if !b0.Leaf() {
+			return
+		}
+		// move b0 into this node
+		if gone != nil {
+			gone(b0)
+		}
+		r.set(b0.key, b0.bits, b0.Value)
+		b0.clear()
+		r.branch[0] = b0.branch[0]
+		r.branch[1] = b0.branch[1]
+		r.adopt()
+		if p := r.root().pool; p != nil {
+			b0.free(p)
+		}
+	}
+	if b1 != nil {
+		if !b1.Leaf() {
+			return
+		}
+		// move b1 into this node
+		if gone != nil {
+			gone(b1)
+		}
+		r.set(b1.key, b1.bits, b1.Value)
+		b1.clear()
+		r.branch[0] = b1.branch[0]
+		r.branch[1] = b1.branch[1]
+		r.adopt()
+		if p := r.root().pool; p != nil {
+			b1.free(p)
+		}
+	}
+	r.parent.prune(false, gone)
+}
+
+// Compact the subtree r, see Compact. Dropped nodes are returned to p when it
+// is not nil.
+func (r *Radix[K, T]) compact(p *sync.Pool) {
+	for i, b := range r.branch {
+		if b == nil {
+			continue
+		}
+		b.compact(p)
+		if b.bits == 0 && b.Leaf() {
+			r.branch[i] = nil
+			if p != nil {
+				b.free(p)
+			}
+		}
+	}
+	if r.bits != 0 {
+		return
+	}
+	c := r.branch[0]
+	if c == nil {
+		c = r.branch[1]
+	} else if r.branch[1] != nil {
+		return
+	}
+	if c == nil || !c.Leaf() {
+		return
+	}
+	r.set(c.key, c.bits, c.Value)
+	c.clear()
+	r.branch[0], r.branch[1] = nil, nil
+	if p != nil {
+		c.free(p)
+	}
+}
+
+// Walk the tree following the bits of n and return the node that holds
+// exactly n/bits, or nil when there is no such node.
+func (r *Radix[K, T]) exact(n K, bits int) *Radix[K, T] {
+	if bits == 0 {
+		return r.dflt
+	}
+	bit := width[K]() - 1
+	for r != nil {
+		if r.bits > 0 && r.bits == bits {
+			mask := ^K(0) << (width[K]() - r.bits)
+			if r.key&mask == n&mask {
+				return r
+			}
+		}
+		if bit < 0 {
+			return nil
+		}
+		r = r.branch[bitK(n, bit)]
+		bit--
+	}
+	return nil
+}
+
+func (r *Radix[K, T]) find(n K, bits, bit int, last *Radix[K, T]) *Radix[K, T] {
+	switch r.Leaf() {
+	case false:
+		// A prefix that is matching and covers n/bits (BETTER MATCHING)
+		mask := ^K(0) << (width[K]() - r.bits)
+		if r.bits > 0 && r.bits <= bits && r.key&mask == n&mask {
+			if last == nil {
+				last = r
+			} else {
+				// Only when bigger
+				if r.bits >= last.bits {
+					last = r
+				}
+			}
+		}
+		if r.bits > 0 && r.bits == bits && r.key&mask == n&mask {
+			// our key
+			return r
+		}
+
+		k := bitK(n, bit)
+		if r.branch[k] == nil {
+			return last // REALLY?
+		}
+		return r.branch[k].find(n, bits, bit-1, last)
+	case true:
+		// It this our key...!? Without bits there is nothing here.
+		mask := ^K(0) << (width[K]() - r.bits)
+		if r.bits > 0 && r.bits <= bits && r.key&mask == n&mask && (last == nil || r.bits >= last.bits) {
+			return r
+		}
+		return last
+	}
+	panic("bitradix: not reached")
+}
+
+// Return a new node, with r as its parent. It is taken from the pool of the
+// tree when it has one.
+func (r *Radix[K, T]) new() *Radix[K, T] {
+	if p := r.root().pool; p != nil {
+		if x, ok := p.Get().(*Radix[K, T]); ok {
+			x.parent = r
+			return x
+		}
+	}
+	var zero T
+
+	return &Radix[K, T]{
+		[2]*Radix[K, T]{nil, nil},
+		r,
+		0,
+		0,
+		0,
+		nil,
+		nil,
+		zero,
+	}
+}
+
+// Zero the nodes in the subtree r and return them to p.
+func (r *Radix[K, T]) free(p *sync.Pool) {
+	for _, b := range r.branch {
+		if b != nil {
+			b.free(p)
+		}
+	}
+	*r = Radix[K, T]{}
+	p.Put(r)
+}
+
+func (r *Radix[K, T]) set(key K, bits int, value T) {
+	switch {
+	case r.bits == 0 && bits > 0:
+		r.root().count++
+	case r.bits > 0 && bits == 0:
+		r.root().count--
+	}
+	if bits == 0 {
+		key = 0 // a key without significant bits, such as the default route, is stored as 0
+	}
+	r.key = key
+	r.bits = bits
+	r.Value = value
+}
+
+func (r *Radix[K, T]) clear() {
+	var zero T
+
+	if r.bits > 0 {
+		r.root().count--
+	}
+	r.key = 0
+	r.bits = 0
+	r.Value = zero
+}
+
+// Return the root of the tree r is part of.
+func (r *Radix[K, T]) root() *Radix[K, T] {
+	for r.parent != nil {
+		r = r.parent
+	}
+	return r
+}
+
+// Return the width of K in bits.
+func width[K Unsigned]() int {
+	return bits.Len64(uint64(^K(0)))
+}
+
+// Return an error when bits is not a valid number of significant bits for K.
+func checkBits[K Unsigned](n K, bits int) error {
+	if w := width[K](); bits < 0 || bits > w {
+		return fmt.Errorf("%w: %d not in [0, %d] for key %0*b", ErrBits, bits, w, w, n)
+	}
+	return nil
+}
+
+// Return bit k from n. We count from the right, MSB left.
+func bitK[K Unsigned](n K, k int) byte {
+	return byte((n >> uint(k)) & 1)
+}
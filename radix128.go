@@ -0,0 +1,427 @@
+package bitradix
+
+import "fmt"
+
+const bitSize128 = 128
+
+// Uint128 is a 128 bits unsigned integer, used as the key in Radix128.
+type Uint128 struct {
+	Hi uint64 // the upper 64 bits
+	Lo uint64 // the lower 64 bits
+}
+
+// Radix128 implements a radix tree with an Uint128 as its key.
+type Radix128[T any] struct {
+	branch [2]*Radix128[T] // branch[0] is left branch for 0, and branch[1] the right for 1
+	parent *Radix128[T]
+	key    Uint128 // the key under which this value is stored
+	bits   int     // the number of significant bits, if 0 the key has not been set.
+	count  int     // the number of keys stored in the tree, only maintained in the root.
+	Value  T       // The value stored.
+}
+
+// New128 returns an empty, initialized Radix128 tree.
+func New128[T any]() *Radix128[T] {
+	r := &Radix128[T]{}
+	// It gets two branches by default
+	r.branch[0] = r.new()
+	r.branch[1] = r.new()
+	return r
+}
+
+// Key returns the key under which this node is stored.
+func (r *Radix128[_]) Key() Uint128 {
+	return r.key
+}
+
+// Bits returns the number of significant bits for the key.
+// A value of zero indicates a key that has not been set.
+func (r *Radix128[_]) Bits() int {
+	return r.bits
+}
+
+// Leaf returns true is r is an leaf node, when false is returned
+// the node is a non-leaf node.
+func (r *Radix128[_]) Leaf() bool {
+	return r.branch[0] == nil && r.branch[1] == nil
+}
+
+// Insert inserts a new value n in the tree r (possibly silently overwriting an existing value).
+// It returns the inserted node, r must be the root of the tree. Insert panics
+// with an ErrBits error when bits is not in the range [1, 128], there is no
+// default route in a Radix128 tree.
+func (r *Radix128[T]) Insert(n Uint128, bits int, v T) *Radix128[T] {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+	if err := checkBits128(n, bits); err != nil {
+		panic(err)
+	}
+
+	if x := r.exact(n, bits); x != nil { // equal keys, overwrite
+		x.set(n, bits, v)
+		return x
+	}
+	return r.insert(n, bits, v, bitSize128-1)
+}
+
+// Remove removes a value from the tree r. It returns the node removed, or nil
+// when nothing is found, r must be the root of the tree. Nothing is found when
+// bits is not in the range [1, 128].
+func (r *Radix128[T]) Remove(n Uint128, bits int) *Radix128[T] {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+	if checkBits128(n, bits) != nil {
+		return nil
+	}
+
+	return r.remove(n, bits, bitSize128-1)
+}
+
+// Find searches the tree for the key n, where the first bits bits of n
+// are significant. It returns the node found or a node with a common prefix. It
+// returns nil when nothing can be found, or when bits is not in the range
+// [1, 128].
+func (r *Radix128[T]) Find(n Uint128, bits int) *Radix128[T] {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+	if checkBits128(n, bits) != nil {
+		return nil
+	}
+
+	return r.find(n, bits, bitSize128-1, nil)
+}
+
+// LongestPrefixMatch returns the node holding the most specific key that covers
+// n, all 128 bits of n are used in the search. The boolean is false when no key
+// covers n. r must be the root of the tree.
+func (r *Radix128[T]) LongestPrefixMatch(n Uint128) (*Radix128[T], bool) {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	var last *Radix128[T]
+	bit := bitSize128 - 1
+	for r != nil {
+		if r.bits > 0 && (last == nil || r.bits > last.bits) {
+			mask := mask128(r.bits)
+			if r.key.and(mask) == n.and(mask) {
+				last = r
+			}
+		}
+		if bit < 0 {
+			break
+		}
+		r = r.branch[bitK128(n, bit)]
+		bit--
+	}
+	return last, last != nil
+}
+
+// Len returns the number of keys stored in the tree r, r must be the root
+// of the tree.
+func (r *Radix128[T]) Len() int {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	return r.count
+}
+
+// Do traverses the tree r in breadth-first order. For each visited node,
+// the function f is called with the current node, and the branch taken
+// (0 for the zero, 1 for the one branch, -1 is used for the root node).
+func (r *Radix128[T]) Do(f func(*Radix128[T], int)) {
+	q := make(queue128[T], 0)
+
+	q.Push(&node128[T]{r, -1})
+	x := q.Pop()
+	for x != nil {
+		f(x.Radix128, x.branch)
+		for i, b := range x.Radix128.branch {
+			if b != nil {
+				q.Push(&node128[T]{b, i})
+			}
+		}
+		x = q.Pop()
+	}
+}
+
+// Implement insert, keys are placed as in Radix64.insert.
+func (r *Radix128[T]) insert(n Uint128, bits int, v T, bit int) *Radix128[T] {
+	switch r.Leaf() {
+	case false: // Non-leaf node, one or two branches, possibly a key
+		if bit < 0 {
+			panic(fmt.Sprintf("bitradix: bit index smaller than zero: key %016x%016x, bits %d, bit %d", n.Hi, n.Lo, bits, bit))
+		}
+		// I should be put here, as I can not go further down, or as this node
+		// is the one with my bits in the path to it
+		if bits == bitSize128-1-bit || bits == bitSize128-bit && (r.bits == 0 || r.bits > bits) {
+			if r.bits > 0 {
+				// move the current key down
+				n1, b1, v1 := r.key, r.bits, r.Value
+				r.set(n, bits, v)
+				bcur := bitK128(n1, bit)
+				if r.branch[bcur] == nil {
+					r.branch[bcur] = r.new()
+				}
+				r.branch[bcur].insert(n1, b1, v1, bit-1)
+				return r
+			}
+			r.set(n, bits, v)
+			return r
+		}
+		bnew := bitK128(n, bit)
+		if r.branch[bnew] == nil {
+			r.branch[bnew] = r.new()
+		}
+		return r.branch[bnew].insert(n, bits, v, bit-1)
+	case true: // External node, (optional) key, no branches
+		if r.bits == 0 || r.key == n && r.bits == bits { // nothing here yet, put something in, or equal keys
+			r.set(n, bits, v)
+			return r
+		}
+		if bit < 0 {
+			panic(fmt.Sprintf("bitradix: bit index smaller than zero: key %016x%016x, bits %d, bit %d", n.Hi, n.Lo, bits, bit))
+		}
+		if bits < r.bits {
+			// the shortest key stays here, the current key moves down
+			n1, b1, v1 := r.key, r.bits, r.Value
+			r.set(n, bits, v)
+			bcur := bitK128(n1, bit)
+			r.branch[bcur] = r.new()
+			r.branch[bcur].insert(n1, b1, v1, bit-1)
+			return r
+		}
+		bnew := bitK128(n, bit)
+		r.branch[bnew] = r.new()
+		return r.branch[bnew].insert(n, bits, v, bit-1)
+	}
+	panic("bitradix: not reached")
+}
+
+// Walk the tree searching for n, keep the last node that has a key in tow.
+// This is the node we should retreat to when we find and delete our node.
+func (r *Radix128[T]) remove(n Uint128, bits, bit int) *Radix128[T] {
+	if r.bits > 0 && r.bits == bits {
+		// possible hit
+		mask := mask128(r.bits)
+		if r.key.and(mask) == n.and(mask) {
+			// save r in r1
+			r1 := &Radix128[T]{
+				[2]*Radix128[T]{nil, nil},
+				nil,
+				r.key,
+				r.bits,
+				0,
+				r.Value,
+			}
+			r.prune(true)
+			return r1
+		}
+	}
+	if bit < 0 {
+		return nil
+	}
+	k := bitK128(n, bit)
+	if r.Leaf() || r.branch[k] == nil { // dead end
+		return nil
+	}
+	return r.branch[k].remove(n, bits, bit-1)
+}
+
+// Set the parent of r's branches to r, after they have been moved.
+func (r *Radix128[T]) adopt() {
+	for _, b := range r.branch {
+		if b != nil {
+			b.parent = r
+		}
+	}
+}
+
+// Prune the tree, when b is true the current node is deleted.
+func (r *Radix128[T]) prune(b bool) {
+	if b {
+		r.clear()
+		if !r.Leaf() {
+			// our subtree stays, we may become redundant
+			r.prune(false)
+			return
+		}
+		if r.parent == nil {
+			return
+		}
+		// we are a node, we have a parent, so the parent is a non-leaf node
+		if r.parent.branch[0] == r {
+			// kill that branch
+			r.parent.branch[0] = nil
+		}
+		if r.parent.branch[1] == r {
+			r.parent.branch[1] = nil
+		}
+		r.parent.prune(false)
+		return
+	}
+	if r == nil {
+		return
+	}
+	if r.bits != 0 {
+		// fun stops
+		return
+	}
+	// Does I have one or two childeren, if one, move my self up one node
+	// Also the child must be a leaf node!
+	b0 := r.branch[0]
+	b1 := r.branch[1]
+	if b0 != nil && b1 != nil {
+		// two branches, we cannot replace ourselves with a child
+		return
+	}
+	if b0 != nil {
+		if !b0.Leaf() {
+			return
+		}
+		// move b0 into this node
+		r.set(b0.key, b0.bits, b0.Value)
+		b0.clear()
+		r.branch[0] = b0.branch[0]
+		r.branch[1] = b0.branch[1]
+		r.adopt()
+	}
+	if b1 != nil {
+		if !b1.Leaf() {
+			return
+		}
+		// move b1 into this node
+		r.set(b1.key, b1.bits, b1.Value)
+		b1.clear()
+		r.branch[0] = b1.branch[0]
+		r.branch[1] = b1.branch[1]
+		r.adopt()
+	}
+	r.parent.prune(false)
+}
+
+// Walk the tree following the bits of n and return the node that holds
+// exactly n/bits, or nil when there is no such node.
+func (r *Radix128[T]) exact(n Uint128, bits int) *Radix128[T] {
+	bit := bitSize128 - 1
+	for r != nil {
+		if r.bits > 0 && r.bits == bits {
+			mask := mask128(r.bits)
+			if r.key.and(mask) == n.and(mask) {
+				return r
+			}
+		}
+		if bit < 0 {
+			return nil
+		}
+		r = r.branch[bitK128(n, bit)]
+		bit--
+	}
+	return nil
+}
+
+func (r *Radix128[T]) find(n Uint128, bits, bit int, last *Radix128[T]) *Radix128[T] {
+	mask := mask128(r.bits)
+	switch r.Leaf() {
+	case false:
+		// A prefix that is matching and covers n/bits (BETTER MATCHING)
+		if r.bits > 0 && r.bits <= bits && r.key.and(mask) == n.and(mask) {
+			if last == nil || r.bits >= last.bits {
+				last = r
+			}
+		}
+		if r.bits > 0 && r.bits == bits && r.key.and(mask) == n.and(mask) {
+			// our key
+			return r
+		}
+		if bit < 0 {
+			return last
+		}
+		k := bitK128(n, bit)
+		if r.branch[k] == nil {
+			return last
+		}
+		return r.branch[k].find(n, bits, bit-1, last)
+	case true:
+		// Without bits there is nothing here.
+		if r.bits > 0 && r.bits <= bits && r.key.and(mask) == n.and(mask) && (last == nil || r.bits >= last.bits) {
+			return r
+		}
+		return last
+	}
+	panic("bitradix: not reached")
+}
+
+// Return a new node, with r as its parent
+func (r *Radix128[T]) new() *Radix128[T] {
+	return &Radix128[T]{parent: r}
+}
+
+func (r *Radix128[T]) set(key Uint128, bits int, value T) {
+	switch {
+	case r.bits == 0 && bits > 0:
+		r.root().count++
+	case r.bits > 0 && bits == 0:
+		r.root().count--
+	}
+	r.key = key
+	r.bits = bits
+	r.Value = value
+}
+
+func (r *Radix128[T]) clear() {
+	var zero T
+
+	if r.bits > 0 {
+		r.root().count--
+	}
+	r.key = Uint128{}
+	r.bits = 0
+	r.Value = zero
+}
+
+// Return the root of the tree r is part of.
+func (r *Radix128[T]) root() *Radix128[T] {
+	for r.parent != nil {
+		r = r.parent
+	}
+	return r
+}
+
+// Return n with only the bits set in m.
+func (n Uint128) and(m Uint128) Uint128 {
+	return Uint128{n.Hi & m.Hi, n.Lo & m.Lo}
+}
+
+// Return a mask with the upper bits bits set, the word boundary at bit 64 is
+// handled by masking Hi and Lo separately.
+func mask128(bits int) Uint128 {
+	switch {
+	case bits <= 0:
+		return Uint128{}
+	case bits <= bitSize64:
+		return Uint128{mask64 << uint(bitSize64-bits), 0}
+	}
+	return Uint128{mask64, mask64 << uint(bitSize128-bits)}
+}
+
+// Return an error when bits is not a valid number of significant bits.
+func checkBits128(n Uint128, bits int) error {
+	if bits < 1 || bits > bitSize128 {
+		return fmt.Errorf("%w: %d not in [1, %d] for key %016x%016x", ErrBits, bits, bitSize128, n.Hi, n.Lo)
+	}
+	return nil
+}
+
+// Return bit k from n. We count from the right, MSB left, so k = 127 is the
+// leftmost bit of Hi and k = 0 the rightmost bit of Lo.
+func bitK128(n Uint128, k int) byte {
+	if k >= bitSize64 {
+		return bitK64(n.Hi, k-bitSize64)
+	}
+	return bitK64(n.Lo, k)
+}
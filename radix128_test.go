@@ -0,0 +1,178 @@
+package bitradix
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/rand/v2"
+	"net/netip"
+	"testing"
+)
+
+func prefixToUint128(t *testing.T, s string) (Uint128, int) {
+	p := netip.MustParsePrefix(s)
+	b := p.Masked().Addr().As16()
+	return Uint128{binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])}, p.Bits()
+}
+
+func addRoute128(t *testing.T, r *Radix128[string], s string) {
+	n, bits := prefixToUint128(t, s)
+	t.Logf("Route %s (%016x%016x/%d)\n", s, n.Hi, n.Lo, bits)
+	r.Insert(n, bits, s)
+}
+
+func TestBitK128(t *testing.T) {
+	n := Uint128{0x8000000000000001, 0x8000000000000001}
+	tests := map[int]byte{127: 1, 126: 0, 64: 1, 63: 1, 62: 0, 1: 0, 0: 1}
+	for k, expected := range tests {
+		if x := bitK128(n, k); x != expected {
+			t.Logf("Expected %d for bit #%d, got %d\n", expected, k, x)
+			t.Fail()
+		}
+	}
+}
+
+func TestMask128(t *testing.T) {
+	tests := map[int]Uint128{
+		0:   {0, 0},
+		1:   {0x8000000000000000, 0},
+		64:  {mask64, 0},
+		65:  {mask64, 0x8000000000000000},
+		128: {mask64, mask64},
+	}
+	for bits, expected := range tests {
+		if x := mask128(bits); x != expected {
+			t.Logf("Expected %016x%016x for /%d, got %016x%016x\n", expected.Hi, expected.Lo, bits, x.Hi, x.Lo)
+			t.Fail()
+		}
+	}
+}
+
+func TestFindIPv6(t *testing.T) {
+	routes := []string{
+		"2001:db8::/32",
+		"2001:db8:1::/48",
+		"2001:db8:1:2::/64",
+		"2001:db8:1:2:3::/80",
+		"2001:db8:1:2:3::1/128",
+		"2a00::/12",
+	}
+	r := New128[string]()
+	for _, route := range routes {
+		addRoute128(t, r, route)
+	}
+	r.Do(func(r1 *Radix128[string], i int) {
+		t.Logf("(%2d): %016x%016x/%d -> %s\n", i, r1.key.Hi, r1.key.Lo, r1.bits, r1.Value)
+	})
+	if l := r.Len(); l != len(routes) {
+		t.Logf("Expected %d, got %d\n", len(routes), l)
+		t.Fail()
+	}
+	for _, route := range routes {
+		n, bits := prefixToUint128(t, route)
+		if x := r.Find(n, bits); x == nil || x.Value != route {
+			t.Logf("Expected %s, got %v\n", route, x)
+			t.Fail()
+		}
+	}
+
+	testips := map[string]string{
+		"2001:db8::1/128":       "2001:db8::/32",
+		"2001:db8:1::1/128":     "2001:db8:1::/48",
+		"2001:db8:1:2::1/128":   "2001:db8:1:2::/64",
+		"2001:db8:1:2:3::2/128": "2001:db8:1:2:3::/80",
+		"2001:db8:1:2:3::1/128": "2001:db8:1:2:3::1/128",
+		"2001:db8:ffff::1/128":  "2001:db8::/32",
+		"2a0f:1:2::1/128":       "2a00::/12",
+		"2001:db9::1/128":       "",
+		"fe80::1/128":           "",
+	}
+	for ip, route := range testips {
+		n, _ := prefixToUint128(t, ip)
+		x, ok := r.LongestPrefixMatch(n)
+		if ok != (route != "") {
+			t.Logf("Expected match %v, got %v for %s\n", route != "", ok, ip)
+			t.Fail()
+			continue
+		}
+		if ok && x.Value != route {
+			t.Logf("Expected %s, got %s for %s\n", route, x.Value, ip)
+			t.Fail()
+		}
+	}
+
+	for i := len(routes) - 1; i >= 0; i-- {
+		n, bits := prefixToUint128(t, routes[i])
+		if x := r.Remove(n, bits); x == nil || x.Value != routes[i] {
+			t.Logf("Failed to remove %s\n", routes[i])
+			t.Fail()
+		}
+	}
+	if l := r.Len(); l != 0 {
+		t.Logf("Expected %d, got %d\n", 0, l)
+		t.Fail()
+	}
+}
+
+func TestRadix128Bits(t *testing.T) {
+	r := New128[string]()
+	n, _ := prefixToUint128(t, "2001:db8::/32")
+	if x := r.Find(n, 32); x != nil {
+		t.Logf("Expected nil in an empty tree, got %v\n", x)
+		t.Fail()
+	}
+	addRoute128(t, r, "2001:db8::/32")
+	m, _ := prefixToUint128(t, "2a00::/12")
+	if x := r.Find(m, 128); x != nil {
+		t.Logf("Expected nil for a miss, got %v\n", x)
+		t.Fail()
+	}
+	for _, bits := range []int{0, 129, 200} {
+		func() {
+			defer func() {
+				if err, _ := recover().(error); !errors.Is(err, ErrBits) {
+					t.Logf("Expected an ErrBits panic for /%d, got %v\n", bits, err)
+					t.Fail()
+				}
+			}()
+			r.Insert(n, bits, "bad")
+		}()
+		if r.Find(n, bits) != nil || r.Remove(n, bits) != nil {
+			t.Logf("Expected nothing to be found for /%d\n", bits)
+			t.Fail()
+		}
+	}
+	if r.Len() != 1 {
+		t.Logf("Expected 1 key, got %d\n", r.Len())
+		t.Fail()
+	}
+}
+
+func TestFindIPv6Linear(t *testing.T) {
+	rnd := rand.New(rand.NewPCG(128, 128))
+	r := New128[int]()
+	type key struct {
+		n    Uint128
+		bits int
+	}
+	keys := map[key]bool{}
+	for i := 0; i < 300; i++ {
+		// Few distinct top bits and short lengths, so keys overlap a lot.
+		bits := 1 + rnd.IntN(12)
+		n := Uint128{rnd.Uint64() & 0xFFF0000000000000, 0}.and(mask128(bits))
+		r.Insert(n, bits, i)
+		keys[key{n, bits}] = true
+	}
+	for i := 0; i < 1000; i++ {
+		n, bits := Uint128{rnd.Uint64(), rnd.Uint64()}, 1+rnd.IntN(bitSize128)
+		found := -1
+		for k := range keys {
+			if k.bits <= bits && n.and(mask128(k.bits)) == k.n {
+				found = max(found, k.bits)
+			}
+		}
+		if x := r.Find(n, bits); (x != nil) != (found >= 0) || x != nil && x.Bits() != found {
+			t.Logf("Expected Find of %016x%016x/%d to return a key of %d bits, got %v\n", n.Hi, n.Lo, bits, found, x)
+			t.Fail()
+		}
+	}
+}
@@ -1,5 +1,5 @@
-// Package bitradix implements a radix tree that branches on the bits of a 32 or
-// 64 bits unsigned integer key.
+// Package bitradix implements a radix tree that branches on the bits of a 32,
+// 64 or 128 bits unsigned integer key.
 //
 // A radix tree is defined in:
 //
@@ -11,6 +11,11 @@
 // http://faculty.simpson.edu/lydia.sinapova/www/cmsc250/LN250_Weiss/L08-Radix.htm
 package bitradix
 
+import "errors"
+
+// ErrBits is returned when the number of significant bits does not fit the key.
+var ErrBits = errors.New("bitradix: bits out of range")
+
 const (
 	bitSize32 = 32
 	bitSize64 = 64
@@ -18,41 +23,13 @@ const (
 	mask64    = 0xFFFFFFFFFFFFFFFF
 )
 
-// Radix32 implements a radix tree with an uint32 as its key.
-type Radix32[T any] struct {
-	branch [2]*Radix32[T] // branch[0] is left branch for 0, and branch[1] the right for 1
-	parent *Radix32[T]
-	key    uint32 // the key under which this value is stored
-	bits   int    // the number of significant bits, if 0 the key has not been set.
-	Value  T      // The value stored.
-}
+// Radix32 implements a radix tree with an uint32 as its key. It is a Radix
+// tree with an uint32 key under its own name, the methods call those of Radix.
+type Radix32[T any] Radix[uint32, T]
 
 // New32 returns an empty, initialized Radix32 tree.
 func New32[T any]() *Radix32[T] {
-	var zero T
-	// It gets two branches by default
-	return &Radix32[T]{
-		[2]*Radix32[T]{
-			{
-				[2]*Radix32[T]{nil, nil},
-				nil,
-				0,
-				0,
-				zero,
-			},
-			{
-				[2]*Radix32[T]{nil, nil},
-				nil,
-				0,
-				0,
-				zero,
-			},
-		},
-		nil,
-		0,
-		0,
-		zero,
-	}
+	return (*Radix32[T])(New[uint32, T]())
 }
 
 // Key returns the key under which this node is stored.
@@ -73,274 +50,49 @@ func (r *Radix32[_]) Leaf() bool {
 }
 
 // Insert inserts a new value n in the tree r (possibly silently overwriting an existing value).
-// It returns the inserted node, r must be the root of the tree.
+// It returns the inserted node, r must be the root of the tree. When bits is
+// zero v becomes the default route, which Find returns for keys nothing else
+// covers. Insert panics when bits is not in the range [0, 32], see InsertE.
 func (r *Radix32[T]) Insert(n uint32, bits int, v T) *Radix32[T] {
-	if r.parent != nil {
-		panic("bitradix: not the root node")
-	}
+	return (*Radix32[T])(r.generic().Insert(n, bits, v))
+}
 
-	return r.insert(n, bits, v, bitSize32-1)
+// InsertE works like Insert, but returns an error instead of panicking when
+// bits is not in the range [0, 32].
+func (r *Radix32[T]) InsertE(n uint32, bits int, v T) (*Radix32[T], error) {
+	x, err := r.generic().InsertE(n, bits, v)
+	return (*Radix32[T])(x), err
 }
 
 // Remove removes a value from the tree r. It returns the node removed, or nil
-// when nothing is found, r must be the root of the tree.
+// when nothing is found, r must be the root of the tree. Nothing is found when
+// bits is not in the range [0, 32].
 func (r *Radix32[T]) Remove(n uint32, bits int) *Radix32[T] {
-	if r.parent != nil {
-		panic("bitradix: not the root node")
-	}
-
-	return r.remove(n, bits, bitSize32-1)
+	return (*Radix32[T])(r.generic().Remove(n, bits))
 }
 
 // Find searches the tree for the key n, where the first bits bits of n
-// are significant. It returns the node found or a node with a common prefix. It
-// returns nil when nothing can be found.
+// are significant. It returns the node found or a node with a common prefix,
+// which is the default route when nothing else covers n/bits. It returns nil
+// when nothing can be found, or when bits is not in the range [0, 32].
 func (r *Radix32[T]) Find(n uint32, bits int) *Radix32[T] {
-	if r.parent != nil {
-		panic("bitradix: not the root node")
-	}
+	return (*Radix32[T])(r.generic().Find(n, bits))
+}
 
-	return r.find(n, bits, bitSize32-1, nil)
+// Len returns the number of keys stored in the tree r, r must be the root
+// of the tree.
+func (r *Radix32[T]) Len() int {
+	return r.generic().Len()
 }
 
 // Do traverses the tree r in breadth-first order. For each visited node,
 // the function f is called with the current node, and the branch taken
 // (0 for the zero, 1 for the one branch, -1 is used for the root node).
 func (r *Radix32[T]) Do(f func(*Radix32[T], int)) {
-	q := make(queue32[T], 0)
-
-	q.Push(&node32[T]{r, -1})
-	x := q.Pop()
-	for x != nil {
-		f(x.Radix32, x.branch)
-		for i, b := range x.Radix32.branch {
-			if b != nil {
-				q.Push(&node32[T]{b, i})
-			}
-		}
-		x = q.Pop()
-	}
-}
-
-// Implement insert
-func (r *Radix32[T]) insert(n uint32, bits int, v T, bit int) *Radix32[T] {
-	switch r.Leaf() {
-	case false: // Non-leaf node, one or two branches, possibly a key
-		if bit < 0 {
-			panic("bitradix: bit index smaller than zero")
-		}
-		bnew := bitK32(n, bit)
-		if r.bits == 0 && bits == bitSize32-bit { // I should be put here
-			r.set(n, bits, v)
-			return r
-		}
-		if r.bits > 0 && bits == bitSize32-bit {
-			bcur := bitK32(r.key, bit)
-			if r.bits > bits {
-				b1 := r.bits
-				n1 := r.key
-				v1 := r.Value
-				r.set(n, bits, v)
-				if r.branch[bcur] == nil {
-					r.branch[bcur] = r.new()
-				}
-				r.branch[bcur].insert(n1, b1, v1, bit-1)
-				return r
-			}
-		}
-		if r.branch[bnew] == nil {
-			r.branch[bnew] = r.new()
-		}
-		return r.branch[bnew].insert(n, bits, v, bit-1)
-	case true: // External node, (optional) key, no branches
-		if r.bits == 0 || r.key == n { // nothing here yet, put something in, or equal keys
-			r.set(n, bits, v)
-			return r
-		}
-		if bit < 0 {
-			panic("bitradix: bit index smaller than zero")
-		}
-		bcur := bitK32(r.key, bit)
-		bnew := bitK32(n, bit)
-		if bcur == bnew {
-			r.branch[bcur] = r.new()
-			if r.bits > 0 && (bits == bitSize32-bit || bits < r.bits) {
-				b1 := r.bits
-				n1 := r.key
-				v1 := r.Value
-				r.set(n, bits, v)
-				r.branch[bnew].insert(n1, b1, v1, bit-1)
-				return r
-			}
-			if r.bits > 0 && bits >= r.bits {
-				// current key can not be put further down, leave it
-				// but continue
-				return r.branch[bnew].insert(n, bits, v, bit-1)
-			}
-			// fill this node, with the current key - and call ourselves
-			r.branch[bcur].set(r.key, r.bits, r.Value)
-			r.clear()
-			return r.branch[bnew].insert(n, bits, v, bit-1)
-		}
-		// not equal, keep current node, and branch off in child
-		r.branch[bcur] = r.new()
-		// fill this node, with the current key - and call ourselves
-		r.branch[bcur].set(r.key, r.bits, r.Value)
-		r.clear()
-		r.branch[bnew] = r.new()
-		return r.branch[bnew].insert(n, bits, v, bit-1)
-	}
-	panic("bitradix: not reached")
-}
-
-// Walk the tree searching for n, keep the last node that has a key in tow.
-// This is the node we should retreat to when we find and delete our node.
-func (r *Radix32[T]) remove(n uint32, bits, bit int) *Radix32[T] {
-	if r.bits > 0 && r.bits == bits {
-		// possible hit
-		mask := uint32(mask32 << (bitSize32 - uint(r.bits)))
-		if r.key&mask == n&mask {
-			// save r in r1
-			r1 := &Radix32[T]{
-				[2]*Radix32[T]{nil, nil},
-				nil,
-				r.key,
-				r.bits,
-				r.Value,
-			}
-			r.prune(true)
-			return r1
-		}
-	}
-	k := bitK32(n, bit)
-	if r.Leaf() || r.branch[k] == nil { // dead end
-		return nil
-	}
-	return r.branch[bitK32(n, bit)].remove(n, bits, bit-1)
-}
-
-// Prune the tree, when b is true the current node is deleted.
-func (r *Radix32[test_value1]) prune(b bool) {
-	if b {
-		if r.parent == nil {
-			r.clear()
-			return
-		}
-		// we are a node, we have a parent, so the parent is a non-leaf node
-		if r.parent.branch[0] == r {
-			// kill that branch
-			r.parent.branch[0] = nil
-		}
-		if r.parent.branch[1] == r {
-			r.parent.branch[1] = nil
-		}
-		r.parent.prune(false)
-		return
-	}
-	if r == nil {
-		return
-	}
-	if r.bits != 0 {
-		// fun stops
-		return
-	}
-	// Does I have one or two childeren, if one, move my self up one node
-	// Also the child must be a leaf node!
-	b0 := r.branch[0]
-	b1 := r.branch[1]
-	if b0 != nil && b1 != nil {
-		// two branches, we cannot replace ourselves with a child
-		return
-	}
-	if b0 != nil {
-		if !b0.Leaf() {
-			return
-		}
-		// move b0 into this node
-		r.set(b0.key, b0.bits, b0.Value)
-		r.branch[0] = b0.branch[0]
-		r.branch[1] = b0.branch[1]
-	}
-	if b1 != nil {
-		if !b1.Leaf() {
-			return
-		}
-		// move b1 into this node
-		r.set(b1.key, b1.bits, b1.Value)
-		r.branch[0] = b1.branch[0]
-		r.branch[1] = b1.branch[1]
-	}
-	r.parent.prune(false)
+	r.generic().Do(func(x *Radix[uint32, T], i int) { f((*Radix32[T])(x), i) })
 }
 
-func (r *Radix32[T]) find(n uint32, bits, bit int, last *Radix32[T]) *Radix32[T] {
-	switch r.Leaf() {
-	case false:
-		// A prefix that is matching (BETTER MATCHING)
-		mask := uint32(mask32 << (bitSize32 - uint(r.bits)))
-		if r.bits > 0 && r.key&mask == n&mask {
-			//			fmt.Printf("Setting last to %d %s\n", r.key, r.Value)
-			if last == nil {
-				last = r
-			} else {
-				// Only when bigger
-				if r.bits >= last.bits {
-					last = r
-				}
-			}
-		}
-		if r.bits == bits && r.key&mask == n&mask {
-			// our key
-			return r
-		}
-
-		k := bitK32(n, bit)
-		if r.branch[k] == nil {
-			return last // REALLY?
-		}
-		return r.branch[k].find(n, bits, bit-1, last)
-	case true:
-		// It this our key...!?
-		mask := uint32(mask32 << (bitSize32 - uint(r.bits)))
-		if r.key&mask == n&mask {
-			return r
-		}
-		return last
-	}
-	panic("bitradix: not reached")
-}
-
-// Return a new node, with r as its parent
-func (r *Radix32[T]) new() *Radix32[T] {
-	var zero T
-
-	return &Radix32[T]{
-		[2]*Radix32[T]{nil, nil},
-		r,
-		0,
-		0,
-		zero,
-	}
-}
-
-func (r *Radix32[T]) set(key uint32, bits int, value T) {
-	r.key = key
-	r.bits = bits
-	r.Value = value
-}
-
-func (r *Radix32[T]) clear() {
-	var zero T
-
-	r.key = 0
-	r.bits = 0
-	r.Value = zero
-}
-
-// From: http://stackoverflow.com/questions/2249731/how-to-get-bit-by-bit-data-from-a-integer-value-in-c
-
-// Return bit k from n. We count from the right, MSB left.
-// So k = 0 is the last bit on the left and k = 31 is the first bit on the right.
-func bitK32(n uint32, k int) byte {
-	return byte((n & (1 << uint(k))) >> uint(k))
+// Return r as the Radix tree it is.
+func (r *Radix32[T]) generic() *Radix[uint32, T] {
+	return (*Radix[uint32, T])(r)
 }
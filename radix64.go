@@ -1,77 +1,1116 @@
 package bitradix
 
-// Radix64 implements a radix tree with an uint64 as its key.
-type Radix64[T any] struct {
-	branch [2]*Radix64[T] // branch[0] is left branch for 0, and branch[1] the right for 1
-	parent *Radix64[T]
-	key    uint64 // the key under which this value is stored
-	bits   int    // the number of significant bits, if 0 the key has not been set.
-	Value  T      // The value stored.
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+// TreeStats holds statistics about the shape of a tree.
+type TreeStats struct {
+	Nodes    int     // the number of nodes
+	Leaves   int     // the number of leaf nodes
+	Keys     int     // the number of nodes holding a key
+	MaxDepth int     // the length of the longest path from the root to a leaf
+	AvgDepth float64 // the average depth of the nodes holding a key
+}
+
+// Entry64 is a key with its number of significant bits and value.
+type Entry64[T any] struct {
+	Key   uint64
+	Bits  int
+	Value T
+}
+
+// Radix64 implements a radix tree with an uint64 as its key. It is a Radix tree
+// with an uint64 key under its own name, which adds the methods that only make
+// sense for that key.
+//
+// A node has room for a single value. To store more with a key, such as the
+// time it was added, make T a struct holding the value and that metadata. Only
+// trees that need it pay for the extra fields.
+type Radix64[T any] Radix[uint64, T]
+
+func New64[T any]() *Radix64[T] {
+	return as64(New[uint64, T]())
+}
+
+// NewLazy64 returns an empty, initialized Radix64 tree without the two
+// branches New64 creates, so an empty tree is a single allocation. Branches are
+// created by Insert when they are needed, and the root itself can hold a key.
+// Apart from its shape the tree works like one returned by New64.
+func NewLazy64[T any]() *Radix64[T] {
+	return &Radix64[T]{}
+}
+
+// NewWithPool64 returns an empty, initialized Radix64 tree that takes its nodes
+// from p, and returns the nodes it no longer uses to p. This reduces the number
+// of allocations when keys are often inserted and removed. Nodes returned by
+// Insert or Find must not be used after their key has been removed. p must only
+// hold *Radix[uint64, T] nodes, which is what a Radix64[T] is made of, its New
+// function may be nil.
+func NewWithPool64[T any](p *sync.Pool) *Radix64[T] {
+	r := &Radix[uint64, T]{pool: p}
+	r.branch[0] = r.new()
+	r.branch[1] = r.new()
+	return as64(r)
+}
+
+func (r *Radix64[_]) Key() uint64 {
+	return r.key
+}
+
+func (r *Radix64[_]) Bits() int {
+	return r.bits
+}
+
+func (r *Radix64[_]) Leaf() bool {
+	return r.branch[0] == nil && r.branch[1] == nil
+}
+
+// Parent returns the parent of the node r, or nil when r is the root.
+func (r *Radix64[T]) Parent() *Radix64[T] {
+	return as64(r.parent)
+}
+
+// Root returns the root of the tree the node r is part of.
+func (r *Radix64[T]) Root() *Radix64[T] {
+	return as64(r.generic().root())
+}
+
+// Branch returns the left (i is 0) or right (i is 1) branch of the node r, which
+// may be nil. Any other i panics.
+func (r *Radix64[T]) Branch(i int) *Radix64[T] {
+	if i != 0 && i != 1 {
+		panic("bitradix: branch index not 0 or 1")
+	}
+	return as64(r.branch[i])
+}
+
+// Children returns the left and right branches of the node r, either may be nil.
+func (r *Radix64[T]) Children() (*Radix64[T], *Radix64[T]) {
+	return as64(r.branch[0]), as64(r.branch[1])
+}
+
+func (r *Radix64[T]) Insert(n uint64, bits int, v T) *Radix64[T] {
+	x, err := r.InsertE(n, bits, v)
+	if err != nil {
+		panic(err)
+	}
+	return x
+}
+
+// FromEntries64 returns a new tree holding entries, see InsertBatch.
+func FromEntries64[T any](entries []Entry64[T]) *Radix64[T] {
+	r := New64[T]()
+	r.InsertBatch(entries)
+	return r
+}
+
+// InsertBatch inserts all entries in the tree r. The entries are sorted as
+// prefixes first, see ComparePrefix64, so that consecutive inserts walk the
+// same part of the tree.
+// When an entry occurs more than once the last one wins, as with Insert. The
+// entries slice itself is not modified. r must be the root of the tree.
+func (r *Radix64[T]) InsertBatch(entries []Entry64[T]) {
+	for _, e := range sortEntries64(entries) {
+		r.Insert(e.Key, e.Bits, e.Value)
+	}
+}
+
+// InsertDedup works like InsertBatch, but skips an entry when the most specific
+// key already in the tree r that covers it makes it redundant: when
+// redundant(covering, covered) returns true for their values. As the entries
+// are sorted as prefixes first, a covering entry is inserted before the entries
+// it covers.
+// r must be the root of the tree.
+func (r *Radix64[T]) InsertDedup(entries []Entry64[T], redundant func(covering, covered T) bool) {
+	for _, e := range sortEntries64(entries) {
+		if x := r.Find(e.Key, e.Bits); x != nil && x.bits < e.Bits && redundant(x.Value, e.Value) {
+			continue
+		}
+		r.Insert(e.Key, e.Bits, e.Value)
+	}
+}
+
+// InsertE works like Insert, but returns an error instead of panicking when
+// bits is not in the range [0, 64].
+func (r *Radix64[T]) InsertE(n uint64, bits int, v T) (*Radix64[T], error) {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+	if err := checkBits64(n, bits); err != nil {
+		return nil, err
+	}
+
+	if x := r.exact(n, bits); x != nil { // equal keys, overwrite
+		x.generic().set(n, bits, v)
+		return x, nil
+	}
+	return r.add(n, bits, v), nil
+}
+
+func (r *Radix64[T]) Remove(n uint64, bits int) *Radix64[T] {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	if checkBits64(n, bits) != nil {
+		return nil
+	}
+	if bits == 0 {
+		return r.removeDefault()
+	}
+	return as64(r.generic().remove(n, bits, bitSize64-1))
+}
+
+// RemoveNotify works like Remove, but calls onGone with every node that loses
+// its key while the tree is pruned: the node of n/bits itself, and the nodes
+// whose key moves up into their parent. onGone is called before the node is
+// changed, so its key can still be read, and the node must not be used after
+// onGone returns. r must be the root of the tree.
+func (r *Radix64[T]) RemoveNotify(n uint64, bits int, onGone func(*Radix64[T])) *Radix64[T] {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	x := r.exact(n, bits)
+	if x == nil {
+		return nil
+	}
+	if x.generic() == r.dflt {
+		onGone(x)
+		return r.removeDefault()
+	}
+	removed := &Radix64[T]{key: x.key, bits: x.bits, Value: x.Value}
+	x.generic().prune(true, func(y *Radix[uint64, T]) { onGone(as64(y)) })
+	return removed
+}
+
+// Delete removes the key n/bits from the tree r. It returns the value removed
+// and true, or the zero value and false when nothing is found. r must be the
+// root of the tree.
+func (r *Radix64[T]) Delete(n uint64, bits int) (T, bool) {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	x := r.exact(n, bits)
+	if x == nil {
+		var zero T
+		return zero, false
+	}
+	v := x.Value
+	if x.generic() == r.dflt {
+		r.removeDefault()
+		return v, true
+	}
+	x.generic().prune(true, nil)
+	return v, true
+}
+
+// DeleteFunc removes all keys from the tree r for which pred returns true, and
+// returns the number of keys removed. It is safe to use even though the tree
+// is modified: the matching keys are collected first and only then removed.
+// Nodes holding a key that still have children are cleared, other nodes are
+// pruned from the tree. r must be the root of the tree.
+func (r *Radix64[T]) DeleteFunc(pred func(key uint64, bits int, v T) bool) int {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	removed := 0
+	if r.dflt != nil && pred(r.dflt.key, r.dflt.bits, r.dflt.Value) {
+		r.removeDefault()
+		removed++
+	}
+	var nodes []*Radix64[T]
+	r.Do(func(r1 *Radix64[T], _ int) {
+		if r1.bits > 0 && pred(r1.key, r1.bits, r1.Value) {
+			nodes = append(nodes, r1)
+		}
+	})
+	return removed + r.drop(nodes)
+}
+
+// RemovePrefix removes n/bits and all keys covered by it from the tree r, and
+// returns the number of keys removed. n/bits itself does not need to be stored.
+// When bits is zero all keys are removed. r must be the root of the tree.
+func (r *Radix64[T]) RemovePrefix(n uint64, bits int) int {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	var nodes []*Radix64[T]
+	r.WalkPrefix(n, bits, func(r1 *Radix64[T], _ int) { nodes = append(nodes, r1) })
+	return r.drop(nodes)
+}
+
+// Find searches the tree for the key n, where the first bits bits of n are
+// significant. It returns the node holding exactly n/bits when there is one,
+// and otherwise the node with the most specific key covering n/bits (the
+// default route when nothing else covers it), or nil. Use ExactMatch to tell
+// those apart. Nothing is found when bits is not in the range [0, 64]. r must
+// be the root of the tree.
+func (r *Radix64[T]) Find(n uint64, bits int) *Radix64[T] {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	if checkBits64(n, bits) != nil {
+		return nil
+	}
+	if bits == 0 {
+		return as64(r.dflt)
+	}
+	if x := as64(r.generic().find(n, bits, bitSize64-1, nil)); x != nil {
+		return x
+	}
+	return as64(r.dflt)
+}
+
+// ExactMatch returns the node holding exactly n/bits and true, or nil and false
+// when n/bits is not stored, even when a key covering it is. r must be the
+// root of the tree.
+func (r *Radix64[T]) ExactMatch(n uint64, bits int) (*Radix64[T], bool) {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	x := r.exact(n, bits)
+	return x, x != nil
+}
+
+// InsertNormalized works like Insert, but zeroes the bits of n after the first
+// bits bits before storing it, see NormalizeKey64. Insert stores n as given,
+// so Key returns the bits outside of the prefix as well.
+func (r *Radix64[T]) InsertNormalized(n uint64, bits int, v T) *Radix64[T] {
+	return r.Insert(NormalizeKey64(n, bits), bits, v)
+}
+
+// InsertNew inserts v under n/bits and returns the new node and true, unless
+// n/bits is already present in the tree r. Then the existing node and false are
+// returned and its value is left alone. r must be the root of the tree.
+func (r *Radix64[T]) InsertNew(n uint64, bits int, v T) (*Radix64[T], bool) {
+	return r.GetOrInsert(n, bits, v)
+}
+
+// GetOrInsert returns the node holding exactly n/bits and false when it is
+// present in the tree r, the existing value is left alone. Otherwise v is
+// inserted and the new node and true are returned. r must be the root of the
+// tree.
+func (r *Radix64[T]) GetOrInsert(n uint64, bits int, v T) (*Radix64[T], bool) {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+	if err := checkBits64(n, bits); err != nil {
+		panic(err)
+	}
+
+	if x := r.exact(n, bits); x != nil {
+		return x, false
+	}
+	return r.add(n, bits, v), true
+}
+
+// Update calls f with the value stored under exactly n/bits and true, or with
+// the zero value and false when there is no such key, and stores the value
+// returned by f under n/bits. It returns the node holding the new value, r must
+// be the root of the tree.
+func (r *Radix64[T]) Update(n uint64, bits int, f func(old T, found bool) T) *Radix64[T] {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+	if err := checkBits64(n, bits); err != nil {
+		panic(err)
+	}
+
+	if x := r.exact(n, bits); x != nil {
+		x.Value = f(x.Value, true)
+		return x
+	}
+	var zero T
+	return r.add(n, bits, f(zero, false))
+}
+
+// Replace stores v under exactly n/bits when that key is present in the tree r,
+// and returns the old value and true. Otherwise the tree is left alone and the
+// zero value and false are returned. r must be the root of the tree.
+func (r *Radix64[T]) Replace(n uint64, bits int, v T) (T, bool) {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	x := r.exact(n, bits)
+	if x == nil {
+		var zero T
+		return zero, false
+	}
+	old := x.Value
+	x.Value = v
+	return old, true
+}
+
+// Contains returns true when the key n with exactly bits significant bits is
+// stored in the tree r. A key covering n/bits does not count. r must be the
+// root of the tree.
+func (r *Radix64[T]) Contains(n uint64, bits int) bool {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	return r.exact(n, bits) != nil
+}
+
+// Overlaps returns true when a key in the tree r covers n/bits, or is
+// covered by n/bits. r must be the root of the tree.
+func (r *Radix64[T]) Overlaps(n uint64, bits int) bool {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	if r.dflt != nil { // the default route overlaps everything
+		return true
+	}
+	bit := bitSize64 - 1
+	for d := 0; d < bits; d++ {
+		if r.bits > 0 {
+			mask := uint64(mask64 << (bitSize64 - uint(min(r.bits, bits))))
+			if r.key&mask == n&mask {
+				return true
+			}
+		}
+		if r = as64(r.branch[bitK64(n, bit)]); r == nil {
+			return false
+		}
+		bit--
+	}
+	// Every key below here shares the first bits bits with n.
+	overlap := false
+	r.DoUntil(func(r1 *Radix64[T], _ int) bool {
+		overlap = r1.bits > 0
+		return !overlap
+	})
+	return overlap
+}
+
+// LongestPrefixMatch returns the node holding the most specific key that covers
+// n, all 64 bits of n are used in the search. The default route matches when
+// no other key covers n. The boolean is false when no key covers n. Other nodes
+// without a key (Bits() is zero) never match. r must be the root of the tree.
+func (r *Radix64[T]) LongestPrefixMatch(n uint64) (*Radix64[T], bool) {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	last := as64(r.dflt)
+	bit := bitSize64 - 1
+	for r != nil {
+		if r.bits > 0 && (last == nil || r.bits > last.bits) {
+			mask := uint64(mask64 << (bitSize64 - uint(r.bits)))
+			if r.key&mask == n&mask {
+				last = r
+			}
+		}
+		if bit < 0 {
+			break
+		}
+		r = as64(r.branch[bitK64(n, bit)])
+		bit--
+	}
+	return last, last != nil
+}
+
+// Match returns the node holding the most specific key that covers n, or nil
+// when no key covers n. All 64 bits of n are used, so unlike Find there is no
+// number of bits to get wrong. r must be the root of the tree.
+func (r *Radix64[T]) Match(n uint64) *Radix64[T] {
+	x, _ := r.LongestPrefixMatch(n)
+	return x
+}
+
+// CoversAddr returns true when a key in the tree r covers n, all 64 bits of n
+// are used. It stops at the first covering key it finds, and the default route
+// covers everything. r must be the root of the tree.
+func (r *Radix64[T]) CoversAddr(n uint64) bool {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	if r.dflt != nil {
+		return true
+	}
+	bit := bitSize64 - 1
+	for r != nil {
+		if r.bits > 0 {
+			mask := uint64(mask64 << (bitSize64 - uint(r.bits)))
+			if r.key&mask == n&mask {
+				return true
+			}
+		}
+		if bit < 0 {
+			break
+		}
+		r = as64(r.branch[bitK64(n, bit)])
+		bit--
+	}
+	return false
+}
+
+// CommonAncestor returns the deepest node in the tree r whose path from the
+// root is a prefix shared by a and b, all 64 bits of a and b are used. When a
+// and b differ in their first bit, or no such node exists below the root, r
+// itself is returned. The node returned does not need to hold a key, and when
+// it does, its key is not necessarily shared by a and b. r must be the root of
+// the tree.
+func (r *Radix64[T]) CommonAncestor(a, b uint64) *Radix64[T] {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	x := r
+	for bit := bitSize64 - 1; bit >= 0; bit-- {
+		k := bitK64(a, bit)
+		if k != bitK64(b, bit) || x.branch[k] == nil {
+			break
+		}
+		x = as64(x.branch[k])
+	}
+	return x
+}
+
+// ShortestPrefixMatch returns the node holding the least specific key that
+// covers n, e.g. a default route when present. The boolean is false when no key
+// covers n. Distinct keys covering n always differ in their number of bits, so
+// there are no ties. r must be the root of the tree.
+func (r *Radix64[T]) ShortestPrefixMatch(n uint64) (*Radix64[T], bool) {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	if r.dflt != nil {
+		return as64(r.dflt), true
+	}
+	var first *Radix64[T]
+	bit := bitSize64 - 1
+	for r != nil {
+		if r.bits > 0 && (first == nil || r.bits < first.bits) {
+			mask := uint64(mask64 << (bitSize64 - uint(r.bits)))
+			if r.key&mask == n&mask {
+				first = r
+			}
+		}
+		if bit < 0 {
+			break
+		}
+		r = as64(r.branch[bitK64(n, bit)])
+		bit--
+	}
+	return first, first != nil
+}
+
+// Get returns the value of the most specific key that covers n, and true. When
+// no key covers n the zero value and false are returned. r must be the root of
+// the tree.
+func (r *Radix64[T]) Get(n uint64) (T, bool) {
+	if x, ok := r.LongestPrefixMatch(n); ok {
+		return x.Value, true
+	}
+	var zero T
+	return zero, false
+}
+
+// Nearest returns the node whose key is closest to target under XOR distance,
+// the number of significant bits of the keys is not used. Subtrees that can not
+// hold a closer key are skipped. The default route is not considered, the
+// boolean is false when the tree holds no other keys. r must be the root of the
+// tree.
+func (r *Radix64[T]) Nearest(target uint64) (*Radix64[T], bool) {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	var best *Radix64[T]
+	r.nearest(target, 0, bitSize64-1, &best)
+	return best, best != nil
+}
+
+// KNearest returns up to k nodes whose keys are closest to target under XOR
+// distance, ordered by ascending distance. Once k nodes are found, subtrees that
+// can not hold a closer key than the k-th are skipped. As with Nearest the
+// default route is not considered. r must be the root of the tree.
+func (r *Radix64[T]) KNearest(target uint64, k int) []*Radix64[T] {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	best := make([]*Radix64[T], 0, max(k, 0))
+	if k > 0 {
+		r.kNearest(target, 0, bitSize64-1, k, &best)
+	}
+	return best
+}
+
+// GetExact returns the value stored under the host key n/64 and true, or the
+// zero value and false when n/64 is not stored. Keys with fewer bits are not
+// considered, so no masks are computed on the way down. r must be the root of
+// the tree.
+func (r *Radix64[T]) GetExact(n uint64) (T, bool) {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	bit := bitSize64 - 1
+	for r != nil {
+		if r.key == n && r.bits == bitSize64 {
+			return r.Value, true
+		}
+		if bit < 0 {
+			break
+		}
+		r = as64(r.branch[n>>uint(bit)&1])
+		bit--
+	}
+	var zero T
+	return zero, false
+}
+
+// GetWithLen works like Get, but also returns the number of bits of the key
+// that matched n, which is zero for the default route. r must be the root of
+// the tree.
+func (r *Radix64[T]) GetWithLen(n uint64) (v T, bits int, found bool) {
+	if x, ok := r.LongestPrefixMatch(n); ok {
+		return x.Value, x.bits, true
+	}
+	return v, 0, false
+}
+
+// Lookup returns the value of the most specific key that covers n, the number
+// of bits of that key, whether it is the host key n/64 itself, and whether any
+// key covers n at all. It does a single search like Get and does not allocate.
+// r must be the root of the tree.
+func (r *Radix64[T]) Lookup(n uint64) (value T, matchedBits int, exact bool, found bool) {
+	x, ok := r.LongestPrefixMatch(n)
+	if !ok {
+		return value, 0, false, false
+	}
+	return x.Value, x.bits, x.bits == bitSize64, true
+}
+
+// GetBatch returns the value of the most specific key that covers each of
+// addrs, the zero value is used when no key covers an address. Repeated
+// addresses in sorted input are looked up once. r must be the root of the tree.
+func (r *Radix64[T]) GetBatch(addrs []uint64) []T {
+	vals := make([]T, len(addrs))
+	r.getBatch(addrs, vals, nil)
+	return vals
+}
+
+// GetBatchOK is like GetBatch, but also returns for each of addrs whether a key
+// covers it. r must be the root of the tree.
+func (r *Radix64[T]) GetBatchOK(addrs []uint64) ([]T, []bool) {
+	vals := make([]T, len(addrs))
+	found := make([]bool, len(addrs))
+	r.getBatch(addrs, vals, found)
+	return vals, found
+}
+
+// Min returns the node holding the smallest key in the tree r, the boolean is
+// false when the tree is empty. For equal keys the one with the fewest
+// significant bits is returned.
+func (r *Radix64[T]) Min() (*Radix64[T], bool) {
+	x := r.extreme(0)
+	return x, x != nil
+}
+
+// Max returns the node holding the largest key in the tree r, the boolean is
+// false when the tree is empty. For equal keys the one with the most
+// significant bits is returned.
+func (r *Radix64[T]) Max() (*Radix64[T], bool) {
+	x := r.extreme(1)
+	return x, x != nil
+}
+
+// Floor returns the node holding the largest key that is equal to or smaller
+// than n, the boolean is false when there is none. For equal keys the one with
+// the most significant bits is returned, and as with Max the default route is
+// not considered. Only the branches that can hold such a key are searched.
+func (r *Radix64[T]) Floor(n uint64) (*Radix64[T], bool) {
+	x := r.floor(n, 0, bitSize64-1)
+	return x, x != nil
+}
+
+// Ceil returns the node holding the smallest key that is equal to or larger
+// than n, the boolean is false when there is none. For equal keys the one with
+// the fewest significant bits is returned, and as with Min the default route is
+// not considered. Only the branches that can hold such a key are searched.
+func (r *Radix64[T]) Ceil(n uint64) (*Radix64[T], bool) {
+	x := r.ceil(n, 0, bitSize64-1)
+	return x, x != nil
+}
+
+// FindAll returns all nodes holding a key that covers n, ordered from the most
+// to the least specific key. When nothing matches an empty slice is returned.
+// r must be the root of the tree.
+func (r *Radix64[T]) FindAll(n uint64) []*Radix64[T] {
+	nodes := r.Supernets(n)
+	if nodes == nil {
+		return []*Radix64[T]{}
+	}
+	slices.Reverse(nodes)
+	return nodes
+}
+
+// GetN returns at most count nodes holding a key that covers n, ordered from
+// the most to the least specific key, like FindAll does. When nothing matches
+// or count is not positive an empty slice is returned. r must be the root of
+// the tree.
+func (r *Radix64[T]) GetN(n uint64, count int) []*Radix64[T] {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	nodes := []*Radix64[T]{}
+	if count <= 0 {
+		return nodes
+	}
+	x := r
+	for bit := bitSize64 - 1; bit >= 0; bit-- {
+		b := as64(x.branch[bitK64(n, bit)])
+		if b == nil {
+			break
+		}
+		x = b
+	}
+	// The keys along the path never get shorter, so walk back up from the
+	// end of it and stop when we have enough.
+	for ; x != nil && len(nodes) < count; x = as64(x.parent) {
+		if x.bits > 0 && NormalizeKey64(n, x.bits) == NormalizeKey64(x.key, x.bits) {
+			nodes = append(nodes, x)
+		}
+	}
+	if len(nodes) < count && r.dflt != nil {
+		nodes = append(nodes, as64(r.dflt))
+	}
+	return nodes
+}
+
+// Supernets returns all nodes holding a key that covers n, ordered from the
+// least to the most specific key. r must be the root of the tree.
+func (r *Radix64[T]) Supernets(n uint64) []*Radix64[T] {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	var nodes []*Radix64[T]
+	if r.dflt != nil {
+		nodes = append(nodes, as64(r.dflt))
+	}
+	bit := bitSize64 - 1
+	for r != nil {
+		if r.bits > 0 {
+			mask := uint64(mask64 << (bitSize64 - uint(r.bits)))
+			if r.key&mask == n&mask {
+				nodes = append(nodes, r)
+			}
+		}
+		if bit < 0 {
+			break
+		}
+		r = as64(r.branch[bitK64(n, bit)])
+		bit--
+	}
+	slices.SortStableFunc(nodes, func(a, b *Radix64[T]) int {
+		return cmp.Compare(a.bits, b.bits)
+	})
+	return nodes
+}
+
+// Len returns the number of keys stored in the tree r, r must be the root
+// of the tree.
+func (r *Radix64[T]) Len() int {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	return r.count
+}
+
+// Empty returns true when the tree r holds no keys, the branches New64 creates
+// do not count. r must be the root of the tree.
+func (r *Radix64[T]) Empty() bool {
+	return r.Len() == 0
+}
+
+// Height returns the number of edges on the longest path from r to a leaf.
+func (r *Radix64[T]) Height() int {
+	h := 0
+	for _, b := range r.branches() {
+		if b != nil {
+			h = max(h, b.Height()+1)
+		}
+	}
+	return h
+}
+
+// Range calls f for every key n in the tree r with lo <= n <= hi, in ascending
+// order of key and then bits, with the node and the branch taken (as in Do).
+// Subtrees that cannot hold such keys are skipped. The default route is not
+// visited. r must be the root of the tree.
+func (r *Radix64[T]) Range(lo, hi uint64, f func(*Radix64[T], int)) {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+	if lo > hi {
+		return
+	}
+
+	var nodes []node64[T]
+	r.keysIn(lo, hi, 0, 0, -1, &nodes)
+	slices.SortFunc(nodes, func(a, b node64[T]) int {
+		if c := cmp.Compare(a.key, b.key); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.bits, b.bits)
+	})
+	for _, x := range nodes {
+		f(x.Radix64, x.branch)
+	}
+}
+
+// CountPrefix returns the number of keys in the tree r that are covered by
+// n/bits, including n/bits itself. When bits is zero all keys are counted. No
+// memory is allocated. r must be the root of the tree.
+func (r *Radix64[T]) CountPrefix(n uint64, bits int) int {
+	c := 0
+	r.WalkPrefix(n, bits, func(*Radix64[T], int) { c++ })
+	return c
+}
+
+// CountFunc returns the number of keys in the tree r for which pred returns
+// true. Only nodes holding a key are passed to pred, and no memory is
+// allocated. r must be the root of the tree.
+func (r *Radix64[T]) CountFunc(pred func(key uint64, bits int, v T) bool) int {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	n := 0
+	if r.dflt != nil && pred(r.dflt.key, r.dflt.bits, r.dflt.Value) {
+		n++
+	}
+	r.walk(func(r1 *Radix64[T], _ int) {
+		if r1.bits > 0 && pred(r1.key, r1.bits, r1.Value) {
+			n++
+		}
+	}, -1)
+	return n
+}
+
+// LengthHistogram returns for every number of bits used by the keys in the
+// tree r how many keys have that number of bits. The default route counts as
+// a key of zero bits. r must be the root of the tree.
+func (r *Radix64[T]) LengthHistogram() map[int]int {
+	h := make(map[int]int)
+	r.ForEach(func(_ uint64, bits int, _ T) { h[bits]++ })
+	return h
+}
+
+// String returns the tree below r with one node per line, indented by depth.
+// Each line shows the branch taken ("root" for r itself), and for nodes
+// holding a key, the key in hexadecimal, the number of bits and the value.
+// It is meant for debugging.
+func (r *Radix64[T]) String() string {
+	var sb strings.Builder
+	r.format(&sb, 0, -1)
+	return sb.String()
+}
+
+// Stats returns statistics about the shape of the tree below r.
+func (r *Radix64[T]) Stats() TreeStats {
+	var s TreeStats
+	depth := 0
+	r.stats(&s, 0, &depth)
+	if s.Keys > 0 {
+		s.AvgDepth = float64(depth) / float64(s.Keys)
+	}
+	return s
 }
 
-func New64[T any]() *Radix64[T] {
-	var zero T
-	// It gets two branches by default
-	return &Radix64[T]{
-		[2]*Radix64[T]{
-			{
-				[2]*Radix64[T]{nil, nil},
-				nil,
-				0,
-				0,
-				zero,
-			},
-			{
-				[2]*Radix64[T]{nil, nil},
-				nil,
-				0,
-				0,
-				zero,
-			},
-		},
-		nil,
-		0,
-		0,
-		zero,
+// ApproxBytes returns an estimate of the memory used by the nodes of the tree
+// r: the number of nodes, including the default route, times the size of a
+// node. Memory the values of type T refer to outside of the nodes is not
+// counted.
+func (r *Radix64[T]) ApproxBytes() int {
+	nodes := 0
+	r.Walk(func(*Radix64[T], int) { nodes++ })
+	if r.dflt != nil {
+		nodes++
 	}
+	return nodes * int(unsafe.Sizeof(*r))
 }
 
-func (r *Radix64[_]) Key() uint64 {
-	return r.key
+// Clone returns a deep copy of the tree r, r must be the root of the tree.
+// The values are copied by assignment.
+func (r *Radix64[T]) Clone() *Radix64[T] {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	return r.clone(nil)
 }
 
-func (r *Radix64[_]) Bits() int {
-	return r.bits
+// Merge inserts all keys from other into r. When a key is present in both trees
+// resolve is called with the existing and the incoming value, and its result is
+// stored. other is not modified. r and other must be root nodes.
+func (r *Radix64[T]) Merge(other *Radix64[T], resolve func(existing, incoming T) T) {
+	if r.parent != nil || other.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	for _, x := range other.sorted() {
+		r.Update(x.key, x.bits, func(old T, found bool) T {
+			if found {
+				return resolve(old, x.Value)
+			}
+			return x.Value
+		})
+	}
 }
 
-func (r *Radix64[_]) Leaf() bool {
-	return r.branch[0] == nil && r.branch[1] == nil
+// Aggregate replaces pairs of sibling keys, two keys of the same length that
+// only differ in their last bit, by the key one bit shorter that covers both,
+// e.g. 10.0.0.0/25 and 10.0.0.128/25 by 10.0.0.0/24. mergeable is called with
+// the values of both siblings, and returns the value for the shorter key and
+// true, or false when the siblings must be left alone. Siblings are not merged
+// when the shorter key is already stored. This is repeated until no more
+// siblings can be merged. r must be the root of the tree.
+func (r *Radix64[T]) Aggregate(mergeable func(a, b T) (T, bool)) {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	for merged := true; merged; {
+		merged = false
+		for _, k := range r.Prefixes() {
+			if k.Bits == 0 {
+				continue
+			}
+			last := uint64(1) << (bitSize64 - k.Bits)
+			if k.Key&last != 0 { // only start from the left sibling
+				continue
+			}
+			a, b := r.exact(k.Key, k.Bits), r.exact(k.Key|last, k.Bits)
+			if a == nil || b == nil || r.exact(k.Key, k.Bits-1) != nil {
+				continue
+			}
+			v, ok := mergeable(a.Value, b.Value)
+			if !ok {
+				continue
+			}
+			r.drop([]*Radix64[T]{a, b})
+			r.add(NormalizeKey64(k.Key, k.Bits-1), k.Bits-1, v)
+			merged = true
+		}
+	}
 }
 
-func (r *Radix64[T]) Insert(n uint64, bits int, v T) *Radix64[T] {
+// Filter returns a new tree holding the keys of r for which keep returns true.
+// r is not modified and must be the root of the tree.
+func (r *Radix64[T]) Filter(keep func(key uint64, bits int, v T) bool) *Radix64[T] {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	f := New64[T]()
+	for _, x := range r.sorted() {
+		if keep(x.key, x.bits, x.Value) {
+			f.Insert(x.key, x.bits, x.Value)
+		}
+	}
+	return f
+}
+
+// Map64 returns a new tree with the same keys as r, and each value v replaced
+// by f(v). The new tree has the same shape as r, which must be the root of the
+// tree.
+func Map64[T, U any](r *Radix64[T], f func(T) U) *Radix64[U] {
 	if r.parent != nil {
 		panic("bitradix: not the root node")
 	}
 
-	return r.insert(n, bits, v, bitSize32-1)
+	return map64(r, nil, f)
 }
 
-func (r *Radix64[T]) Remove(n uint64, bits int) *Radix64[T] {
+// Diff64 compares the keys of the trees old and cur, which must be roots. It
+// returns the keys only in cur as added, the keys only in old as removed, and
+// the keys in both for which eq reports different values as changed, with the
+// value from cur. All three are sorted by key and then bits.
+func Diff64[T any](old, cur *Radix64[T], eq func(a, b T) bool) (added, removed, changed []Entry64[T]) {
+	a, b := old.Entries(), cur.Entries()
+	for len(a) > 0 && len(b) > 0 {
+		c := cmp.Compare(a[0].Key, b[0].Key)
+		if c == 0 {
+			c = cmp.Compare(a[0].Bits, b[0].Bits)
+		}
+		switch {
+		case c < 0:
+			removed = append(removed, a[0])
+			a = a[1:]
+		case c > 0:
+			added = append(added, b[0])
+			b = b[1:]
+		default:
+			if !eq(a[0].Value, b[0].Value) {
+				changed = append(changed, b[0])
+			}
+			a, b = a[1:], b[1:]
+		}
+	}
+	removed = append(removed, a...)
+	added = append(added, b...)
+	return added, removed, changed
+}
+
+// Snapshot returns a deep copy of the tree r that is meant to be read only. As
+// long as no method that modifies a tree is called on the snapshot, it can be
+// read from many goroutines without locking. This allows one writer to update
+// r, and publish a new snapshot through an atomic.Pointer after each change:
+//
+//	var current atomic.Pointer[bitradix.Radix64[T]]
+//
+//	// writer
+//	r.Insert(n, bits, v)
+//	current.Store(r.Snapshot())
+//
+//	// readers
+//	x, ok := current.Load().LongestPrefixMatch(n)
+//
+// Values are copied by assignment, so the values themselves must not be
+// modified when they hold pointers. r must be the root of the tree.
+func (r *Radix64[T]) Snapshot() *Radix64[T] {
+	return r.Clone()
+}
+
+// Clear removes all keys from the tree r, leaving it as returned by New64.
+// r must be the root of the tree.
+func (r *Radix64[T]) Clear() {
 	if r.parent != nil {
 		panic("bitradix: not the root node")
 	}
 
-	return r.remove(n, bits, bitSize32-1)
+	var zero T
+	r.key = 0
+	r.bits = 0
+	r.count = 0
+	r.dflt = nil
+	r.Value = zero
+	r.branch[0] = r.generic().new()
+	r.branch[1] = r.generic().new()
 }
 
-func (r *Radix64[T]) Find(n uint64, bits int) *Radix64[T] {
+// Compact removes the nodes Remove leaves behind: nodes without a key and
+// without branches are dropped, and a node without a key and a single branch
+// takes over the key of that branch when it is a leaf. This works bottom up,
+// so a chain of such nodes collapses into one. A node is never moved up more
+// than that, as its depth decides which bit it examines. The two branches of
+// the root are kept, as New64 creates them. r must be the root of the tree.
+func (r *Radix64[T]) Compact() {
 	if r.parent != nil {
 		panic("bitradix: not the root node")
 	}
 
-	return r.find(n, bits, bitSize32-1, nil)
+	for _, b := range r.branch {
+		if b != nil {
+			b.compact(r.pool)
+		}
+	}
+}
+
+// Keys returns the keys stored in the tree r, sorted by key and then by the
+// number of significant bits.
+func (r *Radix64[T]) Keys() []uint64 {
+	nodes := r.sorted()
+	keys := make([]uint64, len(nodes))
+	for i, x := range nodes {
+		keys[i] = x.key
+	}
+	return keys
+}
+
+// Prefixes returns the keys and their number of significant bits stored in
+// the tree r, in the same order as Keys.
+func (r *Radix64[T]) Prefixes() []struct {
+	Key  uint64
+	Bits int
+} {
+	nodes := r.sorted()
+	prefixes := make([]struct {
+		Key  uint64
+		Bits int
+	}, len(nodes))
+	for i, x := range nodes {
+		prefixes[i].Key = x.key
+		prefixes[i].Bits = x.bits
+	}
+	return prefixes
+}
+
+// Entries returns the keys stored in the tree r with their bits and values, in
+// the same order as Keys.
+func (r *Radix64[T]) Entries() []Entry64[T] {
+	nodes := r.sorted()
+	entries := make([]Entry64[T], len(nodes))
+	for i, x := range nodes {
+		entries[i] = Entry64[T]{x.key, x.bits, x.Value}
+	}
+	return entries
+}
+
+// Values returns the values stored in the tree r, in the same order as Keys.
+func (r *Radix64[T]) Values() []T {
+	nodes := r.sorted()
+	values := make([]T, len(nodes))
+	for i, x := range nodes {
+		values[i] = x.Value
+	}
+	return values
+}
+
+// Equal reports whether r and other hold the same set of keys, each with the same
+// number of bits and a value for which eq returns true. The internal shape of the
+// trees is not compared, as different insertion orders may yield different trees
+// for the same entries. r and other must be root nodes.
+func (r *Radix64[T]) Equal(other *Radix64[T], eq func(a, b T) bool) bool {
+	if r.parent != nil || other.parent != nil {
+		panic("bitradix: not the root node")
+	}
+	if r.count != other.count {
+		return false
+	}
+	a, b := r.sorted(), other.sorted()
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].key != b[i].key || a[i].bits != b[i].bits || !eq(a[i].Value, b[i].Value) {
+			return false
+		}
+	}
+	return true
 }
 
+// Do traverses the tree r in breadth-first order. For each visited node,
+// the function f is called with the current node, and the branch taken
+// (0 for the zero, 1 for the one branch, -1 is used for the root node).
+//
+// All nodes are visited, including nodes without a key (Bits() is zero) such
+// as the two branches New64 creates. The default route is not visited. Use
+// DoEntries to skip the nodes that do not lead to a key.
 func (r *Radix64[T]) Do(f func(*Radix64[T], int)) {
 	q := make(queue64[T], 0)
 
@@ -82,7 +1121,7 @@ func (r *Radix64[T]) Do(f func(*Radix64[T], int)) {
 		for i, b := range x.Radix64.branch {
 			if b != nil {
 				q.Push(&node64[T]{
-					b,
+					as64(b),
 					i,
 				})
 			}
@@ -91,215 +1130,620 @@ func (r *Radix64[T]) Do(f func(*Radix64[T], int)) {
 	}
 }
 
-func (r *Radix64[T]) insert(n uint64, bits int, v T, bit int) *Radix64[T] {
-	switch r.Leaf() {
-	case false: // Non-leaf node, one or two branches, possibly a key
-		if bit < 0 {
-			panic("bitradix: bit index smaller than zero")
-		}
-		bnew := bitK64(n, bit)
-		if r.bits == 0 && bits == bitSize32-bit { // I should be put here
-			r.set(n, bits, v)
-			return r
-		}
-		if r.bits > 0 && bits == bitSize32-bit {
-			bcur := bitK64(r.key, bit)
-			if r.bits > bits {
-				b1 := r.bits
-				n1 := r.key
-				v1 := r.Value
-				r.set(n, bits, v)
-				if r.branch[bcur] == nil {
-					r.branch[bcur] = r.new()
-				}
-				r.branch[bcur].insert(n1, b1, v1, bit-1)
-				return r
-			}
+// DoEntries traverses the tree r in breadth-first order like Do, but skips the
+// nodes without a key that have no keys below them, e.g. the branches of an
+// empty tree. Nodes without a key that lead to a key are visited.
+func (r *Radix64[T]) DoEntries(f func(*Radix64[T], int)) {
+	live := make(map[*Radix64[T]]bool)
+	r.live(live)
+	r.Do(func(r1 *Radix64[T], i int) {
+		if live[r1] {
+			f(r1, i)
 		}
-		if r.branch[bnew] == nil {
-			r.branch[bnew] = r.new()
+	})
+}
+
+// ForEach calls f with the key, bits and value of every key stored in the tree
+// r. Nodes without a key are skipped. r must be the root of the tree.
+func (r *Radix64[T]) ForEach(f func(key uint64, bits int, v T)) {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	if r.dflt != nil {
+		f(r.dflt.key, r.dflt.bits, r.dflt.Value)
+	}
+	r.walk(func(r1 *Radix64[T], _ int) {
+		if r1.bits > 0 {
+			f(r1.key, r1.bits, r1.Value)
 		}
-		return r.branch[bnew].insert(n, bits, v, bit-1)
-	case true: // External node, (optional) key, no branches
-		if r.bits == 0 || r.key == n { // nothing here yet, put something in, or equal keys
-			r.set(n, bits, v)
-			return r
+	}, -1)
+}
+
+// ForEachMut works like ForEach, but passes a pointer to the stored value so f
+// can change it in place. f must not insert or remove keys, as that changes the
+// tree while it is traversed. r must be the root of the tree.
+func (r *Radix64[T]) ForEachMut(f func(key uint64, bits int, v *T)) {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	if r.dflt != nil {
+		f(r.dflt.key, r.dflt.bits, &r.dflt.Value)
+	}
+	r.walk(func(r1 *Radix64[T], _ int) {
+		if r1.bits > 0 {
+			f(r1.key, r1.bits, &r1.Value)
 		}
-		if bit < 0 {
-			panic("bitradix: bit index smaller than zero")
-		}
-		bcur := bitK64(r.key, bit)
-		bnew := bitK64(n, bit)
-		if bcur == bnew {
-			r.branch[bcur] = r.new()
-			if r.bits > 0 && (bits == bitSize32-bit || bits < r.bits) {
-				b1 := r.bits
-				n1 := r.key
-				v1 := r.Value
-				r.set(n, bits, v)
-				r.branch[bnew].insert(n1, b1, v1, bit-1)
-				return r
-			}
-			if r.bits > 0 && bits >= r.bits {
-				// current key can not be put further down, leave it
-				// but continue
-				return r.branch[bnew].insert(n, bits, v, bit-1)
+	}, -1)
+}
+
+// ReverseForEach works like ForEach, but visits the keys from the largest to
+// the smallest key, and for equal keys from the most to the fewest bits. The
+// default route comes last. A key can be stored above smaller keys, so the
+// keys are sorted first. r must be the root of the tree.
+func (r *Radix64[T]) ReverseForEach(f func(key uint64, bits int, v T)) {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	nodes := r.sorted()
+	for i := len(nodes) - 1; i >= 0; i-- {
+		f(nodes[i].key, nodes[i].bits, nodes[i].Value)
+	}
+}
+
+// AtLength calls f with every node holding a key with exactly bits significant
+// bits, a bits of zero visits the default route. A key is not always stored at
+// the depth matching its bits, so no branch can be skipped. r must be the root
+// of the tree.
+func (r *Radix64[T]) AtLength(bits int, f func(*Radix64[T])) {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	if bits == 0 {
+		if r.dflt != nil {
+			f(as64(r.dflt))
+		}
+		return
+	}
+	r.walk(func(r1 *Radix64[T], _ int) {
+		if r1.bits == bits {
+			f(r1)
+		}
+	}, -1)
+}
+
+// DoDepth works like Do, but also passes the depth of the node to f, which is
+// 0 for r itself.
+func (r *Radix64[T]) DoDepth(f func(node *Radix64[T], branch int, depth int)) {
+	type node struct {
+		*Radix64[T]
+		branch, depth int
+	}
+	q := []node{{r, -1, 0}}
+	for len(q) > 0 {
+		x := q[0]
+		q = q[1:]
+		f(x.Radix64, x.branch, x.depth)
+		for i, b := range x.Radix64.branch {
+			if b != nil {
+				q = append(q, node{as64(b), i, x.depth + 1})
 			}
-			// fill this node, with the current key - and call ourselves
-			r.branch[bcur].set(r.key, r.bits, r.Value)
-			r.clear()
-			return r.branch[bnew].insert(n, bits, v, bit-1)
-		}
-		// not equal, keep current node, and branch off in child
-		r.branch[bcur] = r.new()
-		// fill this node, with the current key - and call ourselves
-		r.branch[bcur].set(r.key, r.bits, r.Value)
-		r.clear()
-		r.branch[bnew] = r.new()
-		return r.branch[bnew].insert(n, bits, v, bit-1)
-	}
-	panic("bitradix: not reached")
-}
-
-func (r *Radix64[T]) remove(n uint64, bits, bit int) *Radix64[T] {
-	if r.bits > 0 && r.bits == bits {
-		// possible hit
-		mask := uint64(mask64 << (bitSize32 - uint(r.bits)))
-		if r.key&mask == n&mask {
-			// save r in r1
-			r1 := &Radix64[T]{
-				[2]*Radix64[T]{nil, nil},
-				nil,
-				r.key,
-				r.bits,
-				r.Value,
+		}
+	}
+}
+
+// DoWithBuffer works like Do, but uses the backing array of buf to hold the
+// nodes still to be visited. No memory is allocated when buf has a capacity of
+// at least the number of nodes in the tree, see Stats. buf can be reused for
+// the next call.
+func (r *Radix64[T]) DoWithBuffer(buf []*Radix64[T], f func(*Radix64[T], int)) {
+	q := append(buf[:0], r)
+	for i := 0; i < len(q); i++ {
+		x := q[i]
+		switch {
+		case i == 0:
+			f(x, -1)
+		case x.parent.branch[1] == x.generic():
+			f(x, 1)
+		default:
+			f(x, 0)
+		}
+		for _, b := range x.branches() {
+			if b != nil {
+				q = append(q, b)
 			}
+		}
+	}
+	clear(q) // don't keep the nodes alive through buf
+}
+
+// DoUntil traverses the tree r in breadth-first order like Do, but stops as
+// soon as f returns false.
+func (r *Radix64[T]) DoUntil(f func(*Radix64[T], int) bool) {
+	q := make(queue64[T], 0)
 
-			r.prune(true)
-			return r1
+	q.Push(&node64[T]{r, -1})
+	x := q.Pop()
+	for x != nil {
+		if !f(x.Radix64, x.branch) {
+			return
+		}
+		for i, b := range x.Radix64.branch {
+			if b != nil {
+				q.Push(&node64[T]{as64(b), i})
+			}
 		}
+		x = q.Pop()
 	}
-	k := bitK64(n, bit)
-	if r.Leaf() || r.branch[k] == nil { // dead end
+}
+
+// DoErr traverses the tree r in breadth-first order like Do, but stops at the
+// first error returned by f and returns it. It returns nil when all nodes
+// have been visited.
+func (r *Radix64[T]) DoErr(f func(*Radix64[T], int) error) error {
+	var err error
+	r.DoUntil(func(r1 *Radix64[T], i int) bool {
+		err = f(r1, i)
+		return err == nil
+	})
+	return err
+}
+
+// DoContext traverses the tree r in breadth-first order like Do, but checks ctx
+// before every node. It stops and returns ctx.Err() as soon as ctx is done, and
+// returns nil when all nodes have been visited.
+func (r *Radix64[T]) DoContext(ctx context.Context, f func(*Radix64[T], int)) error {
+	return r.DoErr(func(r1 *Radix64[T], i int) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		f(r1, i)
 		return nil
-	}
-	return r.branch[bitK64(n, bit)].remove(n, bits, bit-1)
+	})
 }
 
-func (r *Radix64[_]) prune(b bool) {
-	if b {
-		if r.parent == nil {
-			r.clear()
+// Walk traverses the tree r in depth-first, in-order: for every node first the
+// zero branch is walked, then f is called with the node and the branch taken
+// (as in Do) and then the one branch is walked. Walk does not allocate.
+func (r *Radix64[T]) Walk(f func(*Radix64[T], int)) {
+	r.walk(f, -1)
+}
+
+// WalkPrefix calls f for every key in the tree r that is covered by n/bits,
+// including n/bits itself, with the node and the branch taken (as in Do).
+// When bits is zero f is called for every key in the tree, starting with the
+// default route, for which the branch is -1. r must be the root of the tree.
+func (r *Radix64[T]) WalkPrefix(n uint64, bits int, f func(*Radix64[T], int)) {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	if bits == 0 && r.dflt != nil {
+		f(as64(r.dflt), -1)
+	}
+
+	mask := uint64(mask64 << (bitSize64 - uint(bits)))
+	covered := func(r1 *Radix64[T], i int) {
+		if r1.bits >= bits && r1.bits > 0 && r1.key&mask == n&mask {
+			f(r1, i)
+		}
+	}
+	// Keys are not always stored at their own depth, so check the nodes
+	// leading up to the subtree of n/bits as well.
+	i := -1
+	bit := bitSize64 - 1
+	for d := 0; d < bits; d++ {
+		covered(r, i)
+		i = int(bitK64(n, bit))
+		if r = as64(r.branch[i]); r == nil {
 			return
 		}
-		// we are a node, we have a parent, so the parent is a non-leaf node
-		if r.parent.branch[0] == r {
-			// kill that branch
-			r.parent.branch[0] = nil
+		bit--
+	}
+	r.walk(covered, i)
+}
+
+// Remove the keys held by nodes from the tree r, and return the number of keys
+// removed. Nodes that still have children are cleared, other nodes are pruned,
+// the default route is removed from the root. Pruning moves keys between nodes, so the keys are collected first and looked
+// up again when removing them.
+func (r *Radix64[T]) drop(nodes []*Radix64[T]) int {
+	keys := make([]struct {
+		key  uint64
+		bits int
+	}, len(nodes))
+	for i, x := range nodes {
+		keys[i].key, keys[i].bits = x.key, x.bits
+	}
+	for _, k := range keys {
+		if k.bits == 0 {
+			r.removeDefault()
+			continue
+		}
+		r.exact(k.key, k.bits).generic().prune(true, nil)
+	}
+	return len(keys)
+}
+
+// Look up each of addrs and store the results in vals and, when not nil, found.
+// An address equal to the previous one reuses its result.
+func (r *Radix64[T]) getBatch(addrs []uint64, vals []T, found []bool) {
+	var (
+		x  *Radix64[T]
+		ok bool
+	)
+	for i, n := range addrs {
+		if i == 0 || n != addrs[i-1] {
+			x, ok = r.LongestPrefixMatch(n)
+		}
+		if ok {
+			vals[i] = x.Value
 		}
-		if r.parent.branch[1] == r {
-			r.parent.branch[1] = nil
+		if found != nil {
+			found[i] = ok
 		}
-		r.parent.prune(false)
-		return
 	}
-	if r == nil {
+}
+
+// Append the nodes below r holding a key in [lo, hi] to nodes. All keys below
+// r start with the first depth bits of prefix.
+func (r *Radix64[T]) keysIn(lo, hi, prefix uint64, depth, i int, nodes *[]node64[T]) {
+	if prefix|mask64>>uint(depth) < lo || prefix > hi {
 		return
 	}
-	if r.bits != 0 {
-		// fun stops
+	if r.bits > 0 && r.key >= lo && r.key <= hi {
+		*nodes = append(*nodes, node64[T]{r, i})
+	}
+	for j, b := range r.branches() {
+		if b != nil {
+			b.keysIn(lo, hi, prefix|uint64(j)<<(bitSize64-1-uint(depth)), depth+1, j, nodes)
+		}
+	}
+}
+
+// Write the subtree r to sb, one node per line indented by depth.
+func (r *Radix64[T]) format(sb *strings.Builder, depth, i int) {
+	sb.WriteString(strings.Repeat("  ", depth))
+	switch i {
+	case -1:
+		sb.WriteString("root")
+	default:
+		fmt.Fprintf(sb, "%d", i)
+	}
+	if r.bits > 0 {
+		fmt.Fprintf(sb, " %016x/%d: %v", r.key, r.bits, r.Value)
+	}
+	sb.WriteByte('\n')
+	if r.dflt != nil {
+		fmt.Fprintf(sb, "%sdefault %016x/0: %v\n", strings.Repeat("  ", depth+1), r.dflt.key, r.dflt.Value)
+	}
+	for j, b := range r.branches() {
+		if b != nil {
+			b.format(sb, depth+1, j)
+		}
+	}
+}
+
+func (r *Radix64[T]) walk(f func(*Radix64[T], int), i int) {
+	if r.branch[0] != nil {
+		as64(r.branch[0]).walk(f, 0)
+	}
+	f(r, i)
+	if r.branch[1] != nil {
+		as64(r.branch[1]).walk(f, 1)
+	}
+}
+
+// Return the node with the smallest (b is 0) or largest (b is 1) key below r.
+// All keys in branch[0] are smaller than the keys in branch[1], only the key
+// of r itself needs to be compared.
+func (r *Radix64[T]) extreme(b int) *Radix64[T] {
+	var x *Radix64[T]
+	if r.bits > 0 {
+		x = r
+	}
+	for _, c := range []*Radix64[T]{as64(r.branch[b]), as64(r.branch[1-b])} {
+		if c == nil {
+			continue
+		}
+		if y := c.extreme(b); y != nil {
+			if x == nil {
+				return y
+			}
+			k := cmp.Compare(y.key, x.key)
+			if k == 0 {
+				k = cmp.Compare(y.bits, x.bits)
+			}
+			if (b == 0 && k < 0) || (b == 1 && k > 0) {
+				return y
+			}
+			return x
+		}
+	}
+	return x
+}
+
+// Search the subtree r for the key closest to target and store it in best when
+// it is closer than best. The nodes below r are reached by prefix, which holds
+// the bits before bit, the bit r examines. All keys below a branch share its
+// prefix, so a branch is skipped when that prefix is already too far away.
+func (r *Radix64[T]) nearest(target, prefix uint64, bit int, best **Radix64[T]) {
+	if r.bits > 0 && (*best == nil || r.key^target < (*best).key^target) {
+		*best = r
+	}
+	if bit < 0 {
 		return
 	}
-	// Does I have one or two childeren, if one, move my self up one node
-	// Also the child must be a leaf node!
-	b0 := r.branch[0]
-	b1 := r.branch[1]
-	if b0 != nil && b1 != nil {
-		// two branches, we cannot replace ourselves with a child
+	b := bitK64(target, bit)
+	for _, i := range [2]byte{b, 1 - b} {
+		c := as64(r.branch[i])
+		if c == nil {
+			continue
+		}
+		p := prefix | uint64(i)<<uint(bit)
+		if *best != nil && (p^target)&(mask64<<uint(bit)) >= (*best).key^target {
+			continue
+		}
+		c.nearest(target, p, bit-1, best)
+	}
+}
+
+// Like nearest, but keep the k closest keys in best, ordered by distance. Until
+// best holds k nodes no branch is skipped.
+func (r *Radix64[T]) kNearest(target, prefix uint64, bit, k int, best *[]*Radix64[T]) {
+	if r.bits > 0 {
+		d := r.key ^ target
+		i, _ := slices.BinarySearchFunc(*best, d, func(x *Radix64[T], d uint64) int {
+			return cmp.Compare(x.key^target, d)
+		})
+		if i < k {
+			if len(*best) == k {
+				*best = (*best)[:k-1]
+			}
+			*best = slices.Insert(*best, i, r)
+		}
+	}
+	if bit < 0 {
 		return
 	}
-	if b0 != nil {
-		if !b0.Leaf() {
-			return
+	b := bitK64(target, bit)
+	for _, i := range [2]byte{b, 1 - b} {
+		c := as64(r.branch[i])
+		if c == nil {
+			continue
+		}
+		p := prefix | uint64(i)<<uint(bit)
+		if len(*best) == k && (p^target)&(mask64<<uint(bit)) >= (*best)[k-1].key^target {
+			continue
 		}
-		// move b0 into this node
-		r.set(b0.key, b0.bits, b0.Value)
-		r.branch[0] = b0.branch[0]
-		r.branch[1] = b0.branch[1]
+		c.kNearest(target, p, bit-1, k, best)
 	}
-	if b1 != nil {
-		if !b1.Leaf() {
-			return
+}
+
+// Mark r and the nodes below it that hold a key, or lead to one, in live.
+// Report whether r is marked.
+func (r *Radix64[T]) live(live map[*Radix64[T]]bool) bool {
+	ok := r.bits > 0
+	for _, b := range r.branches() {
+		if b != nil && b.live(live) {
+			ok = true
 		}
-		// move b1 into this node
-		r.set(b1.key, b1.bits, b1.Value)
-		r.branch[0] = b1.branch[0]
-		r.branch[1] = b1.branch[1]
 	}
-	r.parent.prune(false)
+	if ok {
+		live[r] = true
+	}
+	return ok
 }
 
-func (r *Radix64[T]) find(n uint64, bits, bit int, last *Radix64[T]) *Radix64[T] {
-	switch r.Leaf() {
-	case false:
-		// A prefix that is matching (BETTER MATCHING)
-		mask := uint64(mask64 << (bitSize32 - uint(r.bits)))
-		if r.bits > 0 && r.key&mask == n&mask {
-			//			fmt.Printf("Setting last to %d %s\n", r.key, r.Value)
-			if last == nil {
-				last = r
-			} else {
-				// Only when bigger
-				if r.bits >= last.bits {
-					last = r
-				}
+// Return the node below r with the largest key <= n, see Floor. All keys below r
+// start with the bits of prefix before bit, the bit r examines. A branch whose
+// prefix is larger than n is skipped, and the zero branch is only searched when
+// the one branch holds nothing.
+func (r *Radix64[T]) floor(n, prefix uint64, bit int) *Radix64[T] {
+	var x *Radix64[T]
+	if r.bits > 0 && r.key <= n {
+		x = r
+	}
+	if bit < 0 {
+		return x
+	}
+	for _, i := range [2]int{1, 0} {
+		c := as64(r.branch[i])
+		p := prefix | uint64(i)<<uint(bit)
+		if c == nil || p > n {
+			continue
+		}
+		if y := c.floor(n, p, bit-1); y != nil {
+			if x == nil || compare64(y, x) > 0 {
+				x = y
+			}
+			break
+		}
+	}
+	return x
+}
+
+// Return the node below r with the smallest key >= n, see Ceil and floor.
+func (r *Radix64[T]) ceil(n, prefix uint64, bit int) *Radix64[T] {
+	var x *Radix64[T]
+	if r.bits > 0 && r.key >= n {
+		x = r
+	}
+	if bit < 0 {
+		return x
+	}
+	for _, i := range [2]int{0, 1} {
+		c := as64(r.branch[i])
+		p := prefix | uint64(i)<<uint(bit)
+		if c == nil || p|(1<<uint(bit)-1) < n {
+			continue
+		}
+		if y := c.ceil(n, p, bit-1); y != nil {
+			if x == nil || compare64(y, x) < 0 {
+				x = y
 			}
+			break
+		}
+	}
+	return x
+}
+
+// Gather the statistics of r, which lives at depth d. The depths of the
+// nodes holding a key are summed in depth.
+func (r *Radix64[T]) stats(s *TreeStats, d int, depth *int) {
+	s.Nodes++
+	s.MaxDepth = max(s.MaxDepth, d)
+	if r.Leaf() {
+		s.Leaves++
+	}
+	if r.bits > 0 {
+		s.Keys++
+		*depth += d
+	}
+	for _, b := range r.branches() {
+		if b != nil {
+			b.stats(s, d+1, depth)
 		}
-		if r.bits == bits && r.key&mask == n&mask {
-			// our key
-			return r
+	}
+}
+
+// Return the nodes with a key in the tree r, sorted by key and bits. The
+// default route comes first.
+func (r *Radix64[T]) sorted() []*Radix64[T] {
+	var nodes []*Radix64[T]
+	if r.dflt != nil {
+		nodes = append(nodes, as64(r.dflt))
+	}
+	r.Do(func(r1 *Radix64[T], _ int) {
+		if r1.bits > 0 {
+			nodes = append(nodes, r1)
 		}
+	})
+	slices.SortFunc(nodes, compare64[T])
+	return nodes
+}
 
-		k := bitK64(n, bit)
-		if r.branch[k] == nil {
-			return last // REALLY?
+// Return a copy of the subtree r with its values mapped through f, with parent
+// as its parent. Nodes without a key get the zero value of U.
+func map64[T, U any](r *Radix64[T], parent *Radix64[U], f func(T) U) *Radix64[U] {
+	m := &Radix64[U]{parent: parent.generic(), key: r.key, bits: r.bits, count: r.count}
+	if r.bits > 0 {
+		m.Value = f(r.Value)
+	}
+	if r.dflt != nil {
+		m.dflt = &Radix[uint64, U]{parent: m.generic(), key: r.dflt.key, Value: f(r.dflt.Value)}
+	}
+	for i, b := range r.branches() {
+		if b != nil {
+			m.branch[i] = map64(b, m, f).generic()
 		}
-		return r.branch[k].find(n, bits, bit-1, last)
-	case true:
-		// It this our key...!?
-		mask := uint64(mask64 << (bitSize32 - uint(r.bits)))
-		if r.key&mask == n&mask {
-			return r
+	}
+	return m
+}
+
+// Return a copy of the subtree r, with parent as its parent.
+func (r *Radix64[T]) clone(parent *Radix64[T]) *Radix64[T] {
+	c := &Radix64[T]{parent: parent.generic(), key: r.key, bits: r.bits, count: r.count, Value: r.Value}
+	if r.dflt != nil {
+		c.dflt = as64(r.dflt).clone(c).generic()
+	}
+	for i, b := range r.branches() {
+		if b != nil {
+			c.branch[i] = b.clone(c).generic()
 		}
-		return last
 	}
-	panic("bitradix: not reached")
+	return c
 }
 
-func (r *Radix64[T]) new() *Radix64[T] {
-	var zero T
+// Return the node holding exactly n/bits, see Radix.exact.
+func (r *Radix64[T]) exact(n uint64, bits int) *Radix64[T] {
+	return as64(r.generic().exact(n, bits))
+}
+
+// Insert the key n/bits in the tree r, see Radix.add.
+func (r *Radix64[T]) add(n uint64, bits int, v T) *Radix64[T] {
+	return as64(r.generic().add(n, bits, v))
+}
+
+// Remove the default route from the tree r, see Radix.removeDefault.
+func (r *Radix64[T]) removeDefault() *Radix64[T] {
+	return as64(r.generic().removeDefault())
+}
+
+// Return the branches of r as Radix64 nodes.
+func (r *Radix64[T]) branches() [2]*Radix64[T] {
+	return [2]*Radix64[T]{as64(r.branch[0]), as64(r.branch[1])}
+}
+
+// Return r as the Radix tree it is.
+func (r *Radix64[T]) generic() *Radix[uint64, T] {
+	return (*Radix[uint64, T])(r)
+}
+
+// Return the node x of a Radix tree as a Radix64 node.
+func as64[T any](x *Radix[uint64, T]) *Radix64[T] {
+	return (*Radix64[T])(x)
+}
 
-	return &Radix64[T]{
-		[2]*Radix64[T]{nil, nil},
-		r,
-		0,
-		0,
-		zero,
+// NormalizeKey64 returns n with all bits after the first bits bits set to zero,
+// e.g. 10.0.0.5/24 becomes 10.0.0.0/24 (in the upper 32 bits).
+func NormalizeKey64(n uint64, bits int) uint64 {
+	if bits <= 0 {
+		return 0
 	}
+	return n & uint64(mask64<<(bitSize64-uint(min(bits, bitSize64))))
 }
 
-func (r *Radix64[T]) set(key uint64, bits int, value T) {
-	r.key = key
-	r.bits = bits
-	r.Value = value
+// PrefixEqual64 returns true when the prefixes ka/ba and kb/bb are the same:
+// they have the same number of bits, and their keys are equal in those bits.
+// The bits after the prefix, the host bits, are not compared.
+func PrefixEqual64(ka uint64, ba int, kb uint64, bb int) bool {
+	return ba == bb && NormalizeKey64(ka, ba) == NormalizeKey64(kb, bb)
 }
 
-func (r *Radix64[T]) clear() {
-	var zero T
+// Return an error when bits is not a valid number of significant bits.
+func checkBits64(n uint64, bits int) error {
+	if bits < 0 || bits > bitSize64 {
+		return fmt.Errorf("%w: %d not in [0, %d] for key %064b", ErrBits, bits, bitSize64, n)
+	}
+	return nil
+}
 
-	r.key = 0
-	r.bits = 0
-	r.Value = zero
+// Return a copy of entries, stable sorted as prefixes with ComparePrefix64, so
+// a prefix comes before the prefixes it covers, even when the host bits of its
+// key are set.
+func sortEntries64[T any](entries []Entry64[T]) []Entry64[T] {
+	sorted := slices.Clone(entries)
+	slices.SortStableFunc(sorted, ComparePrefix64[T])
+	return sorted
+}
+
+// ComparePrefix64 orders a and b as prefixes: on their network address, the
+// first Bits bits of Key, ascending, and then the shorter prefix first, so a
+// prefix sorts before the prefixes it covers. Entries with an equal prefix are
+// ordered on the bits of their keys that are not significant. It returns -1, 0
+// or +1 and can be used with slices.SortFunc.
+func ComparePrefix64[T any](a, b Entry64[T]) int {
+	ma := uint64(mask64 << (bitSize64 - uint(a.Bits)))
+	mb := uint64(mask64 << (bitSize64 - uint(b.Bits)))
+	if c := cmp.Compare(a.Key&ma, b.Key&mb); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.Bits, b.Bits); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.Key, b.Key)
+}
+
+// Compare the keys of a and b, and then their number of significant bits.
+func compare64[T any](a, b *Radix64[T]) int {
+	if c := cmp.Compare(a.key, b.key); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.bits, b.bits)
 }
 
 func bitK64(n uint64, k int) byte {
@@ -1,9 +1,20 @@
 package bitradix
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
 	"net"
 	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+	"unsafe"
 )
 
 type bittest struct {
@@ -152,6 +163,39 @@ func TestRemove2(t *testing.T) {
 	})
 }
 
+// Removing a key held by an internal node must keep the keys below it
+func TestRemoveInternal(t *testing.T) {
+	r := New32[uint32]()
+	addRoute(t, r, "10.0.0.0/8", 8)
+	addRoute(t, r, "10.0.0.0/16", 16)
+	addRoute(t, r, "10.128.0.0/16", 128)
+	r.Remove(0x0A000000, 8)
+	if r.Len() != 2 {
+		t.Logf("Expected 2 keys, got %d\n", r.Len())
+		t.Fail()
+	}
+	for ip, asn := range map[string]uint32{"10.0.0.0/16": 16, "10.128.0.0/16": 128, "10.1.0.0/16": 0} {
+		if x := findRoute(t, r, ip); asn != x {
+			t.Logf("Expected %d, got %d for %s\n", asn, x, ip)
+			t.Fail()
+		}
+	}
+
+	r64 := New64[uint32]()
+	addRoute64(t, r64, "10.0.0.0/8", 8)
+	addRoute64(t, r64, "10.0.0.0/16", 16)
+	addRoute64(t, r64, "10.128.0.0/16", 128)
+	r64.Remove(0x0A00000000000000, 8)
+	if r64.Len() != 2 || !r64.Contains(0x0A00000000000000, 16) || !r64.Contains(0x0A80000000000000, 16) {
+		t.Logf("Expected both /16 keys to survive\n")
+		t.Fail()
+	}
+	if r64.Contains(0x0A00000000000000, 8) {
+		t.Logf("Expected 10.0.0.0/8 to be removed\n")
+		t.Fail()
+	}
+}
+
 // Test with "real-life" ip addresses
 func ipToUint(t *testing.T, n *net.IPNet) (i uint32, mask int) {
 	ip := n.IP.To4()
@@ -224,7 +268,7 @@ func TestFindIP(t *testing.T) {
 func TestFindIPShort(t *testing.T) {
 	r := New32[uint32]()
 	// not a map to have influence on the inserting order
-	// The /14 will overwrite the /10 ...
+	// The /14 has the same key as the /8, but both are kept
 	addRoute(t, r, "10.0.0.2/8", 10)
 	addRoute(t, r, "10.0.0.0/14", 11)
 	addRoute(t, r, "10.20.0.0/14", 20)
@@ -255,7 +299,7 @@ func TestFindIPShort(t *testing.T) {
 
 	testips := map[string]uint32{
 		"10.20.1.2/32":     20,
-		"10.19.0.1/32":     0, // because 10.0.0.2/8 isn't there this return 0
+		"10.19.0.1/32":     10,
 		"10.0.0.2/32":      11,
 		"10.1.0.1/32":      11,
 		"210.169.0.0/17":   2516,
@@ -362,7 +406,7 @@ func TestBitK32(t *testing.T) {
 		{0x40, 6}: 1,
 	}
 	for test, expected := range tests {
-		if x := bitK32(test.key, test.bit); x != expected {
+		if x := bitK(test.key, test.bit); x != expected {
 			t.Logf("Expected %d for %032b (bit #%d), got %d\n", expected, test.key, test.bit, x)
 			t.Fail()
 		}
@@ -370,11 +414,11 @@ func TestBitK32(t *testing.T) {
 }
 
 func TestQueue(t *testing.T) {
-	q := make(queue32[uint32], 0)
-	r := New32[uint32]()
+	q := make(queue[uint32, uint32], 0)
+	r := New[uint32, uint32]()
 	r.Value = 10
 
-	q.Push(&node32[uint32]{r, -1})
+	q.Push(&node[uint32, uint32]{r, -1})
 	if r1 := q.Pop(); r1.Value != 10 {
 		t.Logf("Expected %d, got %d\n", 10, r.Value)
 		t.Fail()
@@ -386,10 +430,10 @@ func TestQueue(t *testing.T) {
 }
 
 func TestQueue2(t *testing.T) {
-	q := make(queue32[uint32], 0)
+	q := make(queue[uint32, uint32], 0)
 	tests := []uint32{20, 30, 40}
 	for _, val := range tests {
-		q.Push(&node32[uint32]{&Radix32[uint32]{Value: val}, -1})
+		q.Push(&node[uint32, uint32]{&Radix[uint32, uint32]{Value: val}, -1})
 	}
 	for _, val := range tests {
 		x := q.Pop()
@@ -398,18 +442,18 @@ func TestQueue2(t *testing.T) {
 			t.Fail()
 			continue
 		}
-		if x.Radix32.Value != val {
-			t.Logf("Expected %d, got %d\n", val, x.Radix32.Value)
+		if x.Radix.Value != val {
+			t.Logf("Expected %d, got %d\n", val, x.Radix.Value)
 			t.Fail()
 		}
 	}
 	if x := q.Pop(); x != nil {
-		t.Logf("Expected nil, got %d\n", x.Radix32.Value)
+		t.Logf("Expected nil, got %d\n", x.Radix.Value)
 		t.Fail()
 	}
 	// Push and pop again, see if that works too
 	for _, val := range tests {
-		q.Push(&node32[uint32]{&Radix32[uint32]{Value: val}, -1})
+		q.Push(&node[uint32, uint32]{&Radix[uint32, uint32]{Value: val}, -1})
 	}
 	for _, val := range tests {
 		x := q.Pop()
@@ -418,8 +462,23 @@ func TestQueue2(t *testing.T) {
 			t.Fail()
 			continue
 		}
-		if x.Radix32.Value != val {
-			t.Logf("Expected %d, got %d\n", val, x.Radix32.Value)
+		if x.Radix.Value != val {
+			t.Logf("Expected %d, got %d\n", val, x.Radix.Value)
+			t.Fail()
+		}
+	}
+}
+
+func TestQueuePopClears(t *testing.T) {
+	q := make(queue64[uint64], 0, 3)
+	for _, val := range []uint64{20, 30, 40} {
+		q.Push(&node64[uint64]{&Radix64[uint64]{Value: val}, -1})
+	}
+	backing := q[:3]
+	for i := range backing {
+		q.Pop()
+		if backing[i] != nil {
+			t.Logf("Expected popped slot %d to be nil, got %v\n", i, backing[i].Value)
 			t.Fail()
 		}
 	}
@@ -442,3 +501,2853 @@ func TestPanic64(t *testing.T) {
 		r.Insert(k, 64, k)
 	}
 }
+
+func TestPanicMessage64(t *testing.T) {
+	defer func() {
+		msg, _ := recover().(string)
+		for _, want := range []string{fmt.Sprintf("%064b", uint64(0xABCD)), "bits 64", "bit -1"} {
+			if !strings.Contains(msg, want) {
+				t.Logf("Expected %q in the panic message %q\n", want, msg)
+				t.Fail()
+			}
+		}
+	}()
+	// The root of a new tree has branches, so it can not take a key this deep.
+	New64[uint64]().generic().insert(0xABCD, 64, 1, -1)
+}
+
+func TestFindTopBits64(t *testing.T) {
+	tests := []struct {
+		key   uint64
+		bits  int
+		value uint64
+	}{
+		{0x8000000000000000, 1, 1},
+		{0x4000000000000000, 2, 2},
+		{0xC000000000000000, 2, 3},
+		{0xFFFFFFFF00000000, 32, 4},
+		{0xFFFFFFFE00000000, 32, 5},
+		{0x2001_0DB8_0000_0000, 64, 6},
+		{0x2001_0DB8_0000_0001, 64, 7},
+	}
+	r := New64[uint64]()
+	for _, test := range tests {
+		r.Insert(test.key, test.bits, test.value)
+	}
+	r.Do(func(r1 *Radix64[uint64], i int) { t.Logf("(%2d): %064b/%d -> %d\n", i, r1.key, r1.bits, r1.Value) })
+	for _, test := range tests {
+		x := r.Find(test.key, test.bits)
+		if x == nil {
+			t.Logf("Got nil for %064b/%d\n", test.key, test.bits)
+			t.Fail()
+			continue
+		}
+		if x.Value != test.value {
+			t.Logf("Expected %d, got %d for %064b/%d\n", test.value, x.Value, test.key, test.bits)
+			t.Fail()
+		}
+	}
+}
+
+// Count the keys in the tree the slow way.
+func keys32(r *Radix32[uint32]) (n int) {
+	r.Do(func(r1 *Radix32[uint32], _ int) {
+		if r1.bits > 0 {
+			n++
+		}
+	})
+	return
+}
+
+func TestLen(t *testing.T) {
+	r := New32[uint32]()
+	if l := r.Len(); l != 0 {
+		t.Logf("Expected %d, got %d for empty tree\n", 0, l)
+		t.Fail()
+	}
+	r = newTree32()
+	if l := r.Len(); l != len(tests) {
+		t.Logf("Expected %d, got %d\n", len(tests), l)
+		t.Fail()
+	}
+	// Overwrite does not add a key
+	r.Insert(0x80000000, bits32, 2014)
+	if l := r.Len(); l != len(tests) {
+		t.Logf("Expected %d after overwrite, got %d\n", len(tests), l)
+		t.Fail()
+	}
+	for k := range tests {
+		r.Remove(k, bits32)
+		if l, k1 := r.Len(), keys32(r); l != k1 {
+			t.Logf("Expected %d after removal of %032b, got %d\n", k1, k, l)
+			t.Fail()
+		}
+	}
+	if l := r.Len(); l != 0 {
+		t.Logf("Expected %d, got %d\n", 0, l)
+		t.Fail()
+	}
+}
+
+func TestLenRoutes(t *testing.T) {
+	r := New32[uint32]()
+	routes := []string{
+		"10.0.0.0/8", "10.20.0.0/14", "10.21.0.0/16", "192.168.0.0/16", "192.168.2.0/24",
+		"8.0.0.0/9", "8.8.8.0/24", "210.166.0.0/19", "210.166.5.0/24", "210.167.0.0/19",
+	}
+	for i, route := range routes {
+		addRoute(t, r, route, uint32(i))
+		addRoute(t, r, route, uint32(i)) // inserting twice must not double count
+		if l := r.Len(); l != i+1 {
+			t.Logf("Expected %d after insert of %s, got %d\n", i+1, route, l)
+			t.Fail()
+		}
+	}
+	// Removing the most specific routes first prunes the internal nodes
+	for _, route := range []string{"210.166.5.0/24", "8.8.8.0/24", "192.168.2.0/24", "10.21.0.0/16"} {
+		_, ipnet, _ := net.ParseCIDR(route)
+		n, mask := ipToUint(t, ipnet)
+		r.Remove(n, mask)
+		if l, k := r.Len(), keys32(r); l != k {
+			t.Logf("Expected %d after removal of %s, got %d\n", k, route, l)
+			t.Fail()
+		}
+	}
+}
+
+func TestLen64(t *testing.T) {
+	r := New64[uint64]()
+	var k uint64
+	for k = 0; k <= 255; k++ {
+		r.Insert(k<<56, 8, k)
+		r.Insert(k<<56, 8, k)
+	}
+	if l := r.Len(); l != 256 {
+		t.Logf("Expected %d, got %d\n", 256, l)
+		t.Fail()
+	}
+	for k = 0; k <= 255; k++ {
+		r.Remove(k<<56, 8)
+	}
+	if l := r.Len(); l != 0 {
+		t.Logf("Expected %d, got %d\n", 0, l)
+		t.Fail()
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	r := New64[int]()
+	if !r.Empty() {
+		t.Logf("Expected a new tree to be empty\n")
+		t.Fail()
+	}
+	r.Insert(0x0A00000000000000, 8, 10)
+	if r.Empty() {
+		t.Logf("Expected a tree with one key not to be empty\n")
+		t.Fail()
+	}
+	r.Remove(0x0A00000000000000, 8)
+	if !r.Empty() {
+		t.Logf("Expected the tree to be empty after removal\n")
+		t.Fail()
+	}
+}
+
+// Test with IPv4 addresses stored in the upper 32 bits of a Radix64.
+func ipToUint64(t *testing.T, n *net.IPNet) (uint64, int) {
+	i, mask := ipToUint(t, n)
+	return uint64(i) << 32, mask
+}
+
+func addRoute64(t *testing.T, r *Radix64[uint32], s string, asn uint32) {
+	_, ipnet, _ := net.ParseCIDR(s)
+	net, mask := ipToUint64(t, ipnet)
+	t.Logf("Route %s (%064b), AS %d\n", s, net, asn)
+	r.Insert(net, mask, asn)
+}
+
+func TestLongestPrefixMatch(t *testing.T) {
+	routes := []string{"10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24"}
+	testips := map[string]uint32{
+		"10.1.2.3":    24,
+		"10.1.3.1":    16,
+		"10.2.0.1":    8,
+		"10.255.0.0":  8,
+		"11.0.0.1":    0,
+		"192.168.0.1": 0,
+	}
+	// Insert in both orders
+	for _, order := range [][]int{{0, 1, 2}, {2, 1, 0}} {
+		r := New64[uint32]()
+		for _, i := range order {
+			addRoute64(t, r, routes[i], uint32(8*(i+1)))
+		}
+		for ip, asn := range testips {
+			n, _ := ipToUint64(t, &net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(32, 32)})
+			x, ok := r.LongestPrefixMatch(n)
+			if ok != (asn != 0) {
+				t.Logf("Expected match %v, got %v for %s\n", asn != 0, ok, ip)
+				t.Fail()
+				continue
+			}
+			if ok && x.Value != asn {
+				t.Logf("Expected %d, got %d for %s\n", asn, x.Value, ip)
+				t.Fail()
+			}
+		}
+	}
+}
+
+// A key must never end up below the node where its bits end, or lookups
+// following the path of an address miss it.
+func TestLongestPrefixMatchLinear(t *testing.T) {
+	r := New64[string]()
+	r.Insert(0xC0<<56, 2, "A")
+	r.Insert(0x80<<56, 5, "X")
+	r.Insert(0xE0<<56, 8, "Y")
+	if x, ok := r.LongestPrefixMatch(0xF0 << 56); !ok || x.Value != "A" {
+		t.Logf("Expected A to match\n")
+		t.Fail()
+	}
+	if x := r.Find(0xF0<<56, 64); x == nil || x.Value != "A" {
+		t.Logf("Expected to find A\n")
+		t.Fail()
+	}
+	if !r.Overlaps(0xF0<<56, 4) {
+		t.Logf("Expected 0xF0/4 to overlap A\n")
+		t.Fail()
+	}
+
+	rnd := rand.New(rand.NewPCG(3, 3))
+	for round := 0; round < 20; round++ {
+		r := New64[int]()
+		var keys []Entry64[int]
+		for i := 0; i < 200; i++ {
+			// Few distinct top bits and short lengths, so keys overlap a lot.
+			bits := 1 + rnd.IntN(12)
+			key := rnd.Uint64() & 0xFFF0000000000000
+			r.Insert(key, bits, i)
+			keys = append(keys, Entry64[int]{key, bits, i})
+		}
+		// The last insert of a prefix wins.
+		want := make(map[[2]uint64]int)
+		for _, k := range keys {
+			want[[2]uint64{NormalizeKey64(k.Key, k.Bits), uint64(k.Bits)}] = k.Value
+		}
+		for i := 0; i < 1000; i++ {
+			n := rnd.Uint64()
+			best, shortest, covering := -1, bitSize64+1, 0
+			for k := range want {
+				mask := uint64(mask64 << (bitSize64 - uint(k[1])))
+				if n&mask == k[0] {
+					best, shortest = max(best, int(k[1])), min(shortest, int(k[1]))
+					covering++
+				}
+			}
+			x, ok := r.LongestPrefixMatch(n)
+			if ok != (best >= 0) || ok && (x.Bits() != best || x.Value != want[[2]uint64{NormalizeKey64(n, best), uint64(best)}]) {
+				t.Logf("Expected the longest match of %064b to have %d bits, got %v\n", n, best, x)
+				t.Fail()
+			}
+			if r.CoversAddr(n) != (best >= 0) {
+				t.Logf("Expected CoversAddr of %064b to be %t\n", n, best >= 0)
+				t.Fail()
+			}
+			if x, ok := r.ShortestPrefixMatch(n); ok != (best >= 0) || ok && x.Bits() != shortest {
+				t.Logf("Expected the shortest match of %064b to have %d bits\n", n, shortest)
+				t.Fail()
+			}
+			if s := r.Supernets(n); len(s) != covering {
+				t.Logf("Expected %d supernets of %064b, got %d\n", covering, n, len(s))
+				t.Fail()
+			}
+			// Find only considers keys that cover n/bits.
+			bits, found := rnd.IntN(13), -1
+			for k := range want {
+				mask := uint64(mask64 << (bitSize64 - uint(k[1])))
+				if int(k[1]) <= bits && n&mask == k[0] {
+					found = max(found, int(k[1]))
+				}
+			}
+			if x := r.Find(n, bits); (x != nil) != (found >= 0) || x != nil && x.Bits() != found {
+				t.Logf("Expected Find of %064b/%d to return a key of %d bits, got %v\n", n, bits, found, x)
+				t.Fail()
+			}
+		}
+	}
+}
+
+func TestInsertE(t *testing.T) {
+	r := New64[uint64]()
+	for _, bits := range []int{-1, 65, 128} {
+		x, err := r.InsertE(0x8000000000000000, bits, 1)
+		if !errors.Is(err, ErrBits) || x != nil {
+			t.Logf("Expected ErrBits for bits %d, got %v\n", bits, err)
+			t.Fail()
+		}
+	}
+	if l := r.Len(); l != 0 {
+		t.Logf("Expected %d, got %d\n", 0, l)
+		t.Fail()
+	}
+	for _, bits := range []int{1, 32, 64} {
+		x, err := r.InsertE(0x8000000000000000, bits, uint64(bits))
+		if err != nil {
+			t.Logf("Expected no error for bits %d, got %v\n", bits, err)
+			t.Fail()
+			continue
+		}
+		if x.Value != uint64(bits) {
+			t.Logf("Expected %d, got %d\n", bits, x.Value)
+			t.Fail()
+		}
+	}
+}
+
+func TestRadix32Bits(t *testing.T) {
+	r := New32[int]()
+	// Zero bits is the default route.
+	if x, err := r.InsertE(5, 0, 9); err != nil || x.Value != 9 {
+		t.Logf("Expected no error for bits 0, got %v\n", err)
+		t.Fail()
+	}
+	if x := r.Find(5, 0); r.Len() != 1 || x == nil || x.Value != 9 {
+		t.Logf("Expected to find the default route\n")
+		t.Fail()
+	}
+	if x := r.Find(0x0A000000, 8); x == nil || x.Value != 9 {
+		t.Logf("Expected 0x0A000000/8 to fall under the default route\n")
+		t.Fail()
+	}
+	// 33 bits does not fit.
+	x, err := r.InsertE(5, 33, 1)
+	if !errors.Is(err, ErrBits) || x != nil || !strings.Contains(err.Error(), "not in [0, 32]") {
+		t.Logf("Expected ErrBits with the range [0, 32] for bits 33, got %v\n", err)
+		t.Fail()
+	}
+	if r.Len() != 1 {
+		t.Logf("Expected 1 key, got %d\n", r.Len())
+		t.Fail()
+	}
+}
+
+func TestBitsOutOfRange(t *testing.T) {
+	r32 := New32[uint32]()
+	r32.Insert(0x0A000000, 8, 10)
+	for _, bits := range []int{-1, 33, 40} {
+		if x, err := r32.InsertE(0x0A000000, bits, 1); !errors.Is(err, ErrBits) || x != nil {
+			t.Logf("Expected ErrBits for bits %d, got %v\n", bits, err)
+			t.Fail()
+		}
+		if r32.Find(0x0A000000, bits) != nil || r32.Remove(0x0A000000, bits) != nil {
+			t.Logf("Expected nothing to be found for bits %d\n", bits)
+			t.Fail()
+		}
+	}
+	if x := r32.Find(0x0A010000, 16); r32.Len() != 1 || x == nil || x.Value != 10 {
+		t.Logf("Expected the tree to be left alone\n")
+		t.Fail()
+	}
+	func() {
+		defer func() {
+			if err, _ := recover().(error); !errors.Is(err, ErrBits) {
+				t.Logf("Expected Insert to panic with ErrBits, got %v\n", err)
+				t.Fail()
+			}
+		}()
+		r32.Insert(0x0A000000, 40, 1)
+	}()
+
+	r64 := New64[uint32]()
+	r64.Insert(0x0A00000000000000, 8, 10)
+	for _, bits := range []int{-1, 65, 100} {
+		if r64.Find(0x0A00000000000000, bits) != nil || r64.Remove(0x0A00000000000000, bits) != nil {
+			t.Logf("Expected nothing to be found for bits %d\n", bits)
+			t.Fail()
+		}
+	}
+	if r64.Len() != 1 || !r64.Contains(0x0A00000000000000, 8) {
+		t.Logf("Expected the tree to be left alone\n")
+		t.Fail()
+	}
+}
+
+func TestClone(t *testing.T) {
+	r := New64[uint32]()
+	routes := map[string]uint32{
+		"10.0.0.0/8":     10,
+		"10.20.0.0/14":   20,
+		"10.21.0.0/16":   21,
+		"192.168.0.0/16": 192,
+		"192.168.2.0/24": 1922,
+		"8.0.0.0/9":      3356,
+		"8.8.8.0/24":     15169,
+	}
+	for route, asn := range routes {
+		addRoute64(t, r, route, asn)
+	}
+	c := r.Clone()
+	if c.parent != nil {
+		t.Logf("Expected clone to be a root node\n")
+		t.Fail()
+	}
+	if c.Len() != r.Len() {
+		t.Logf("Expected %d, got %d\n", r.Len(), c.Len())
+		t.Fail()
+	}
+	for route := range routes {
+		_, ipnet, _ := net.ParseCIDR(route)
+		n, mask := ipToUint64(t, ipnet)
+		c.Remove(n, mask)
+	}
+	if l := c.Len(); l != 0 {
+		t.Logf("Expected %d, got %d for clone\n", 0, l)
+		t.Fail()
+	}
+	if l := r.Len(); l != len(routes) {
+		t.Logf("Expected %d, got %d for original\n", len(routes), l)
+		t.Fail()
+	}
+	for route, asn := range routes {
+		_, ipnet, _ := net.ParseCIDR(route)
+		n, mask := ipToUint64(t, ipnet)
+		if x := r.Find(n, mask); x == nil || x.Value != asn {
+			t.Logf("Expected %d for %s in original\n", asn, route)
+			t.Fail()
+		}
+	}
+	// Every node in the clone must point to its parent in the clone
+	r.Clone().Do(func(r1 *Radix64[uint32], i int) {
+		for _, b := range r1.branch {
+			if b != nil && b.parent != r1.generic() {
+				t.Logf("Parent of %064b/%d not rewired\n", b.key, b.bits)
+				t.Fail()
+			}
+		}
+	})
+}
+
+func TestKeys(t *testing.T) {
+	routes := []string{"10.0.0.0/8", "10.20.0.0/14", "10.21.0.0/16", "192.168.0.0/16", "192.168.2.0/24", "8.0.0.0/9", "8.8.8.0/24"}
+	expected := []string{"8.0.0.0/9", "8.8.8.0/24", "10.0.0.0/8", "10.20.0.0/14", "10.21.0.0/16", "192.168.0.0/16", "192.168.2.0/24"}
+	// Insert in different orders, the result must be the same
+	for _, order := range [][]int{{0, 1, 2, 3, 4, 5, 6}, {6, 5, 4, 3, 2, 1, 0}, {3, 0, 6, 1, 5, 2, 4}} {
+		r := New64[uint32]()
+		for _, i := range order {
+			addRoute64(t, r, routes[i], uint32(i))
+		}
+		keys, prefixes, values := r.Keys(), r.Prefixes(), r.Values()
+		if len(keys) != len(expected) || len(prefixes) != len(expected) || len(values) != len(expected) {
+			t.Logf("Expected %d entries, got %d, %d and %d\n", len(expected), len(keys), len(prefixes), len(values))
+			t.Fail()
+			continue
+		}
+		for i, route := range expected {
+			_, ipnet, _ := net.ParseCIDR(route)
+			n, mask := ipToUint64(t, ipnet)
+			if keys[i] != n || prefixes[i].Key != n || prefixes[i].Bits != mask {
+				t.Logf("Expected %s at %d, got %064b/%d\n", route, i, prefixes[i].Key, prefixes[i].Bits)
+				t.Fail()
+			}
+			if routes[values[i]] != route {
+				t.Logf("Expected value for %s at %d, got %s\n", route, i, routes[values[i]])
+				t.Fail()
+			}
+		}
+	}
+	if keys := New64[uint32]().Keys(); len(keys) != 0 {
+		t.Logf("Expected no keys, got %d\n", len(keys))
+		t.Fail()
+	}
+}
+
+func TestWalk(t *testing.T) {
+	r := New64[uint32]()
+	r.Insert(0x8000000000000000, bits32, 2012)
+	r.Insert(0x4000000000000000, bits32, 2010)
+	r.Insert(0x9000000000000000, bits32, 2013)
+	// The tree looks like:
+	//            (-1)
+	//     0x40/5      0x80/5
+	//              0x90/5
+	expected := []struct {
+		key    uint64
+		branch int
+	}{
+		{0x4000000000000000, 0},
+		{0, -1},
+		{0x9000000000000000, 0},
+		{0x8000000000000000, 1},
+	}
+	i := 0
+	r.Walk(func(r1 *Radix64[uint32], b int) {
+		t.Logf("(%2d): %064b/%d -> %d\n", b, r1.key, r1.bits, r1.Value)
+		if i >= len(expected) {
+			t.Logf("Visited more than %d nodes\n", len(expected))
+			t.Fail()
+			return
+		}
+		if r1.key != expected[i].key || b != expected[i].branch {
+			t.Logf("Expected %064b (%d) at %d, got %064b (%d)\n", expected[i].key, expected[i].branch, i, r1.key, b)
+			t.Fail()
+		}
+		i++
+	})
+	if i != len(expected) {
+		t.Logf("Expected %d nodes, got %d\n", len(expected), i)
+		t.Fail()
+	}
+	if a := testing.AllocsPerRun(10, func() { r.Walk(func(*Radix64[uint32], int) {}) }); a != 0 {
+		t.Logf("Expected no allocations, got %f\n", a)
+		t.Fail()
+	}
+}
+
+func TestDoUntil(t *testing.T) {
+	r := New64[uint64]()
+	var k uint64
+	for k = 1; k <= 16; k++ {
+		r.Insert(k<<56, 8, k)
+	}
+	all := 0
+	r.Do(func(*Radix64[uint64], int) { all++ })
+	seen := 0
+	r.DoUntil(func(*Radix64[uint64], int) bool { seen++; return true })
+	if seen != all {
+		t.Logf("Expected %d nodes, got %d\n", all, seen)
+		t.Fail()
+	}
+	// Stop at the first node with a key
+	var found *Radix64[uint64]
+	seen = 0
+	r.DoUntil(func(r1 *Radix64[uint64], _ int) bool {
+		seen++
+		if found != nil {
+			t.Logf("Called after returning false\n")
+			t.Fail()
+		}
+		if r1.bits > 0 {
+			found = r1
+			return false
+		}
+		return true
+	})
+	if found == nil || seen >= all {
+		t.Logf("Expected to stop early, visited %d of %d nodes\n", seen, all)
+		t.Fail()
+	}
+}
+
+func TestDoEntries(t *testing.T) {
+	r := New64[int]()
+	all, seen := 0, 0
+	r.Do(func(*Radix64[int], int) { all++ })
+	r.DoEntries(func(*Radix64[int], int) { seen++ })
+	if all != 3 || seen != 0 {
+		t.Logf("Expected 3 and 0 nodes in an empty tree, got %d and %d\n", all, seen)
+		t.Fail()
+	}
+
+	r.Insert(0x0A00000000000000, 8, 10)
+	r.Insert(0x0A01000000000000, 16, 11)
+	r.Insert(0x0B00000000000000, 8, 11)
+	all, seen = 0, 0
+	r.Do(func(*Radix64[int], int) { all++ })
+	r.DoEntries(func(r1 *Radix64[int], _ int) {
+		seen++
+		if r1.Bits() > 0 {
+			return
+		}
+		// a node without a key must lead to one
+		keys := 0
+		r1.Do(func(r2 *Radix64[int], _ int) {
+			if r2.Bits() > 0 {
+				keys++
+			}
+		})
+		if keys == 0 {
+			t.Logf("Expected no empty subtree at %016x\n", r1.Key())
+			t.Fail()
+		}
+	})
+	if seen == 0 || seen >= all {
+		t.Logf("Expected DoEntries to skip empty nodes, visited %d of %d\n", seen, all)
+		t.Fail()
+	}
+}
+
+func TestDoErr(t *testing.T) {
+	r := New64[uint64]()
+	var k uint64
+	for k = 1; k <= 16; k++ {
+		r.Insert(k<<56, 8, k)
+	}
+	all := 0
+	r.Do(func(*Radix64[uint64], int) { all++ })
+	seen := 0
+	if err := r.DoErr(func(*Radix64[uint64], int) error { seen++; return nil }); err != nil || seen != all {
+		t.Logf("Expected %d nodes and no error, got %d and %v\n", all, seen, err)
+		t.Fail()
+	}
+	errBad := errors.New("bad value")
+	seen = 0
+	err := r.DoErr(func(r1 *Radix64[uint64], _ int) error {
+		seen++
+		if r1.Value == 4 {
+			return errBad
+		}
+		return nil
+	})
+	if err != errBad || seen >= all {
+		t.Logf("Expected to stop early with %v, got %v after %d of %d nodes\n", errBad, err, seen, all)
+		t.Fail()
+	}
+}
+
+func TestDoContext(t *testing.T) {
+	r := New64[uint64]()
+	for k := uint64(1); k <= 16; k++ {
+		r.Insert(k<<56, 8, k)
+	}
+	all := 0
+	r.Do(func(*Radix64[uint64], int) { all++ })
+	seen := 0
+	if err := r.DoContext(context.Background(), func(*Radix64[uint64], int) { seen++ }); err != nil || seen != all {
+		t.Logf("Expected %d nodes and no error, got %d and %v\n", all, seen, err)
+		t.Fail()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	seen = 0
+	err := r.DoContext(ctx, func(*Radix64[uint64], int) {
+		seen++
+		if seen == 3 {
+			cancel()
+		}
+	})
+	if !errors.Is(err, context.Canceled) || seen != 3 {
+		t.Logf("Expected to stop after 3 nodes with %v, got %d and %v\n", context.Canceled, seen, err)
+		t.Fail()
+	}
+}
+
+func TestClear(t *testing.T) {
+	dump := func(r *Radix64[uint32]) (s []string) {
+		r.Do(func(r1 *Radix64[uint32], i int) {
+			s = append(s, fmt.Sprintf("(%2d): %064b/%d -> %d", i, r1.key, r1.bits, r1.Value))
+		})
+		return s
+	}
+	r := New64[uint32]()
+	for _, route := range []string{"10.0.0.0/8", "10.20.0.0/14", "192.168.0.0/16", "8.8.8.0/24"} {
+		addRoute64(t, r, route, 1)
+	}
+	r.Clear()
+	if l := r.Len(); l != 0 {
+		t.Logf("Expected %d, got %d\n", 0, l)
+		t.Fail()
+	}
+	if !reflect.DeepEqual(dump(r), dump(New64[uint32]())) {
+		t.Logf("Expected cleared tree to equal a new tree\n")
+		t.Fail()
+	}
+	n := New64[uint32]()
+	for _, route := range []string{"10.21.0.0/16", "192.168.2.0/24", "8.0.0.0/9"} {
+		addRoute64(t, r, route, 2)
+		addRoute64(t, n, route, 2)
+	}
+	if !reflect.DeepEqual(dump(r), dump(n)) {
+		t.Logf("Expected cleared tree to behave as a new tree\n")
+		t.Fail()
+	}
+	if r.Len() != n.Len() {
+		t.Logf("Expected %d, got %d\n", n.Len(), r.Len())
+		t.Fail()
+	}
+}
+
+func TestContains(t *testing.T) {
+	r := New64[uint32]()
+	addRoute64(t, r, "10.0.0.0/8", 10)
+	addRoute64(t, r, "10.21.0.0/16", 21)
+	tests := map[string]bool{
+		"10.0.0.0/8":     true,  // exact hit
+		"10.21.0.0/16":   true,  // exact hit
+		"10.20.0.0/16":   false, // covered by 10.0.0.0/8
+		"10.21.1.0/24":   false, // covered by 10.21.0.0/16
+		"10.0.0.0/7":     false, // covers 10.0.0.0/8
+		"192.168.0.0/16": false, // miss
+	}
+	for route, expected := range tests {
+		_, ipnet, _ := net.ParseCIDR(route)
+		n, mask := ipToUint64(t, ipnet)
+		if x := r.Contains(n, mask); x != expected {
+			t.Logf("Expected %v, got %v for %s\n", expected, x, route)
+			t.Fail()
+		}
+	}
+	// host bits are ignored
+	if !r.Contains(0x0A00000500000000, 8) {
+		t.Logf("Expected %v, got %v for 10.0.0.5/8\n", true, false)
+		t.Fail()
+	}
+}
+
+func TestGetOrInsert(t *testing.T) {
+	r := New64[uint32]()
+	x, inserted := r.GetOrInsert(0x0A00000000000000, 8, 1)
+	if !inserted || x.Value != 1 {
+		t.Logf("Expected insert of %d, got %d (%v)\n", 1, x.Value, inserted)
+		t.Fail()
+	}
+	y, inserted := r.GetOrInsert(0x0A00000000000000, 8, 2)
+	if inserted || y != x || y.Value != 1 {
+		t.Logf("Expected existing value %d, got %d (%v)\n", 1, y.Value, inserted)
+		t.Fail()
+	}
+	// A counter map
+	for i := 0; i < 3; i++ {
+		x, _ := r.GetOrInsert(0x0A01000000000000, 16, 0)
+		x.Value++
+	}
+	if x := r.Find(0x0A01000000000000, 16); x == nil || x.Value != 3 {
+		t.Logf("Expected counter to be %d\n", 3)
+		t.Fail()
+	}
+	if l := r.Len(); l != 2 {
+		t.Logf("Expected %d, got %d\n", 2, l)
+		t.Fail()
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	r := New64[uint32]()
+	calls := 0
+	count := func(old uint32, found bool) uint32 {
+		if found != (calls > 0) {
+			t.Logf("Expected found to be %v on call %d\n", calls > 0, calls)
+			t.Fail()
+		}
+		calls++
+		return old + 1
+	}
+	for i := 0; i < 5; i++ {
+		r.Update(0x0A00000000000000, 8, count)
+	}
+	if x := r.Find(0x0A00000000000000, 8); x == nil || x.Value != 5 {
+		t.Logf("Expected counter to be %d\n", 5)
+		t.Fail()
+	}
+	// Initialize on first touch
+	x := r.Update(0x0A01000000000000, 16, func(old uint32, found bool) uint32 {
+		if found || old != 0 {
+			t.Logf("Expected zero value and not found, got %d (%v)\n", old, found)
+			t.Fail()
+		}
+		return 100
+	})
+	if x.Value != 100 || x.Bits() != 16 {
+		t.Logf("Expected %d, got %d\n", 100, x.Value)
+		t.Fail()
+	}
+	if l := r.Len(); l != 2 {
+		t.Logf("Expected %d, got %d\n", 2, l)
+		t.Fail()
+	}
+}
+
+func TestReplace(t *testing.T) {
+	r := New64[string]()
+	r.Insert(0x0A00000000000000, 8, "ten")
+	if old, ok := r.Replace(0x0A00000000000000, 8, "TEN"); !ok || old != "ten" {
+		t.Logf("Expected to replace %q, got %q (%t)\n", "ten", old, ok)
+		t.Fail()
+	}
+	if v, _ := r.Get(0x0A01000000000000); v != "TEN" {
+		t.Logf("Expected %q, got %q\n", "TEN", v)
+		t.Fail()
+	}
+	if old, ok := r.Replace(0x0A01000000000000, 16, "ten-one"); ok || old != "" {
+		t.Logf("Expected nothing to replace, got %q (%t)\n", old, ok)
+		t.Fail()
+	}
+	if r.Len() != 1 || r.Contains(0x0A01000000000000, 16) {
+		t.Logf("Expected the tree to be left alone\n")
+		t.Fail()
+	}
+}
+
+func TestWalkPrefix(t *testing.T) {
+	r := New64[uint32]()
+	routes := []string{
+		"10.0.0.0/8", "10.1.0.0/16", "10.20.0.0/14", "10.21.0.0/16", "10.21.3.0/24",
+		"11.0.0.0/8", "192.168.0.0/16", "192.168.2.0/24", "8.0.0.0/9", "8.8.8.0/24",
+	}
+	for i, route := range routes {
+		addRoute64(t, r, route, uint32(i))
+	}
+	tests := map[string][]string{
+		"10.0.0.0/8":     {"10.0.0.0/8", "10.1.0.0/16", "10.20.0.0/14", "10.21.0.0/16", "10.21.3.0/24"},
+		"10.20.0.0/14":   {"10.20.0.0/14", "10.21.0.0/16", "10.21.3.0/24"},
+		"10.21.0.0/16":   {"10.21.0.0/16", "10.21.3.0/24"}, // prefix itself exists
+		"10.21.0.0/20":   {"10.21.3.0/24"},                 // only more specifics exist
+		"192.168.0.0/17": {"192.168.2.0/24"},
+		"172.16.0.0/12":  {},
+		"0.0.0.0/0":      routes,
+	}
+	for prefix, expected := range tests {
+		_, ipnet, _ := net.ParseCIDR(prefix)
+		n, mask := ipToUint64(t, ipnet)
+		seen := map[string]bool{}
+		r.WalkPrefix(n, mask, func(r1 *Radix64[uint32], _ int) {
+			seen[routes[r1.Value]] = true
+		})
+		if len(seen) != len(expected) {
+			t.Logf("Expected %d keys under %s, got %v\n", len(expected), prefix, seen)
+			t.Fail()
+		}
+		for _, route := range expected {
+			if !seen[route] {
+				t.Logf("Expected %s under %s\n", route, prefix)
+				t.Fail()
+			}
+		}
+	}
+
+	// The default route is covered by 0/0 only.
+	r.Insert(0, 0, uint32(len(routes)))
+	dflt := 0
+	r.WalkPrefix(0, 0, func(r1 *Radix64[uint32], i int) {
+		if r1.Bits() == 0 && i == -1 {
+			dflt++
+		}
+	})
+	if dflt != 1 {
+		t.Logf("Expected the default route to be walked once, got %d\n", dflt)
+		t.Fail()
+	}
+	if c := r.CountPrefix(0, 0); c != len(routes)+1 {
+		t.Logf("Expected %d keys under 0/0, got %d\n", len(routes)+1, c)
+		t.Fail()
+	}
+	if c := r.CountPrefix(0x0A00000000000000, 8); c != 5 {
+		t.Logf("Expected 5 keys under 10.0.0.0/8, got %d\n", c)
+		t.Fail()
+	}
+	if n := r.RemovePrefix(0, 0); n != len(routes)+1 || r.Len() != 0 {
+		t.Logf("Expected all %d keys removed, got %d with %d left\n", len(routes)+1, n, r.Len())
+		t.Fail()
+	}
+}
+
+func TestSupernets(t *testing.T) {
+	r := New64[uint32]()
+	// Insert the most specific first, so they are not stored in order
+	addRoute64(t, r, "10.1.2.0/24", 24)
+	addRoute64(t, r, "10.1.0.0/16", 16)
+	addRoute64(t, r, "10.0.0.0/8", 8)
+	addRoute64(t, r, "192.168.0.0/16", 16)
+
+	n, _ := ipToUint64(t, &net.IPNet{IP: net.ParseIP("10.1.2.3"), Mask: net.CIDRMask(32, 32)})
+	nodes := r.Supernets(n)
+	expected := []uint32{8, 16, 24}
+	if len(nodes) != len(expected) {
+		t.Logf("Expected %d supernets, got %d\n", len(expected), len(nodes))
+		t.FailNow()
+	}
+	for i, x := range nodes {
+		if x.Value != expected[i] || x.Bits() != int(expected[i]) {
+			t.Logf("Expected /%d at %d, got /%d\n", expected[i], i, x.Bits())
+			t.Fail()
+		}
+	}
+	n, _ = ipToUint64(t, &net.IPNet{IP: net.ParseIP("172.16.0.1"), Mask: net.CIDRMask(32, 32)})
+	if nodes := r.Supernets(n); len(nodes) != 0 {
+		t.Logf("Expected no supernets, got %d\n", len(nodes))
+		t.Fail()
+	}
+}
+
+func TestOverlaps(t *testing.T) {
+	r := New64[uint32]()
+	addRoute64(t, r, "10.0.0.0/8", 8)
+	addRoute64(t, r, "192.168.2.0/24", 24)
+	tests := map[string]bool{
+		"10.0.0.0/8":     true,  // equal
+		"10.1.0.0/16":    true,  // contained by 10.0.0.0/8
+		"10.1.2.3/32":    true,  // contained by 10.0.0.0/8
+		"8.0.0.0/6":      true,  // contains 10.0.0.0/8
+		"192.168.0.0/16": true,  // contains 192.168.2.0/24
+		"0.0.0.0/0":      true,  // contains everything
+		"11.0.0.0/8":     false, // sibling of 10.0.0.0/8
+		"192.168.3.0/24": false, // sibling of 192.168.2.0/24
+		"172.16.0.0/12":  false,
+	}
+	for prefix, expected := range tests {
+		_, ipnet, _ := net.ParseCIDR(prefix)
+		n, mask := ipToUint64(t, ipnet)
+		if x := r.Overlaps(n, mask); x != expected {
+			t.Logf("Expected %v, got %v for %s\n", expected, x, prefix)
+			t.Fail()
+		}
+	}
+	if l := r.Len(); l != 2 {
+		t.Logf("Expected %d, got %d\n", 2, l)
+		t.Fail()
+	}
+	if New64[uint32]().Overlaps(0, 0) {
+		t.Logf("Expected no overlap in an empty tree\n")
+		t.Fail()
+	}
+}
+
+func TestApproxBytes(t *testing.T) {
+	size := int(unsafe.Sizeof(Radix64[uint64]{}))
+	r := New64[uint64]()
+	if b := r.ApproxBytes(); b != 3*size {
+		t.Logf("Expected %d bytes, got %d\n", 3*size, b)
+		t.Fail()
+	}
+	for k := uint64(0); k < 16; k++ {
+		r.Insert(k<<56, 8, k)
+		nodes := r.Stats().Nodes
+		if b := r.ApproxBytes(); b != nodes*size {
+			t.Logf("Expected %d bytes for %d nodes, got %d\n", nodes*size, nodes, b)
+			t.Fail()
+		}
+	}
+	b := r.ApproxBytes()
+	r.Insert(0, 0, 0)
+	if b1 := r.ApproxBytes(); b1 != b+size {
+		t.Logf("Expected the default route to be counted, got %d bytes\n", b1)
+		t.Fail()
+	}
+}
+
+func TestStats(t *testing.T) {
+	// A left leaning tree, every key is pushed one level down:
+	//          (-1)
+	//      0x00/8   (empty)
+	//    0x01/8
+	//  0x02/8
+	// 0x03/8
+	left := New64[uint64]()
+	for k := uint64(0); k < 4; k++ {
+		left.Insert(k<<56, 8, k)
+	}
+	// Keys of two bits stay in the first free node on their path:
+	//          (-1)
+	//    0x00/2    0x80/2
+	//       0x40/2    0xC0/2
+	short := New64[uint64]()
+	for k := uint64(0); k < 4; k++ {
+		short.Insert(k<<62, 2, k)
+	}
+	tests := []struct {
+		r      *Radix64[uint64]
+		height int
+		stats  TreeStats
+	}{
+		{New64[uint64](), 1, TreeStats{Nodes: 3, Leaves: 2, Keys: 0, MaxDepth: 1, AvgDepth: 0}},
+		{left, 4, TreeStats{Nodes: 6, Leaves: 2, Keys: 4, MaxDepth: 4, AvgDepth: 2.5}},
+		{short, 2, TreeStats{Nodes: 5, Leaves: 2, Keys: 4, MaxDepth: 2, AvgDepth: 1.5}},
+	}
+	for i, test := range tests {
+		if h := test.r.Height(); h != test.height {
+			t.Logf("Expected height %d, got %d for tree %d\n", test.height, h, i)
+			t.Fail()
+		}
+		if s := test.r.Stats(); s != test.stats {
+			t.Logf("Expected %+v, got %+v for tree %d\n", test.stats, s, i)
+			t.Fail()
+		}
+	}
+}
+
+func TestMinMax(t *testing.T) {
+	r := New64[uint32]()
+	if _, ok := r.Min(); ok {
+		t.Logf("Expected no minimum in an empty tree\n")
+		t.Fail()
+	}
+	if _, ok := r.Max(); ok {
+		t.Logf("Expected no maximum in an empty tree\n")
+		t.Fail()
+	}
+	addRoute64(t, r, "10.0.0.0/8", 10)
+	x, ok1 := r.Min()
+	y, ok2 := r.Max()
+	if !ok1 || !ok2 || x != y || x.Value != 10 {
+		t.Logf("Expected single entry to be minimum and maximum\n")
+		t.Fail()
+	}
+	for _, route := range []string{"192.168.2.0/24", "8.8.8.0/24", "10.21.0.0/16", "192.168.0.0/16", "8.0.0.0/9", "200.0.0.0/8"} {
+		addRoute64(t, r, route, 1)
+	}
+	keys := r.Keys()
+	if x, ok := r.Min(); !ok || x.Key() != keys[0] {
+		t.Logf("Expected minimum %064b, got %v\n", keys[0], x)
+		t.Fail()
+	}
+	if x, ok := r.Max(); !ok || x.Key() != keys[len(keys)-1] {
+		t.Logf("Expected maximum %064b, got %v\n", keys[len(keys)-1], x)
+		t.Fail()
+	}
+}
+
+func TestDelete(t *testing.T) {
+	r := New64[uint32]()
+	addRoute64(t, r, "10.0.0.0/8", 10)
+	addRoute64(t, r, "10.21.0.0/16", 21)
+	if v, ok := r.Delete(0x0A15000000000000, 16); !ok || v != 21 {
+		t.Logf("Expected %d, got %d (%v)\n", 21, v, ok)
+		t.Fail()
+	}
+	if v, ok := r.Delete(0x0A15000000000000, 16); ok || v != 0 {
+		t.Logf("Expected nothing, got %d (%v)\n", v, ok)
+		t.Fail()
+	}
+	if v, ok := r.Delete(0x0A00000000000000, 16); ok {
+		t.Logf("Expected nothing for a covered prefix, got %d (%v)\n", v, ok)
+		t.Fail()
+	}
+	if l := r.Len(); l != 1 {
+		t.Logf("Expected %d, got %d\n", 1, l)
+		t.Fail()
+	}
+	if v, ok := r.Delete(0x0A00000000000000, 8); !ok || v != 10 {
+		t.Logf("Expected %d, got %d (%v)\n", 10, v, ok)
+		t.Fail()
+	}
+	if l := r.Len(); l != 0 {
+		t.Logf("Expected %d, got %d\n", 0, l)
+		t.Fail()
+	}
+}
+
+func randomEntries64(n int) []Entry64[int] {
+	rnd := rand.New(rand.NewPCG(64, 64))
+	entries := make([]Entry64[int], 0, n)
+	seen := make(map[uint64]bool)
+	for len(entries) < n {
+		bits := 8 + rnd.IntN(25)
+		// Set the last significant bit, so keys of different lengths never mask to the same value.
+		key := rnd.Uint64()&(mask64<<(bitSize64-bits)) | 1<<(bitSize64-bits)
+		if !seen[key] {
+			seen[key] = true
+			entries = append(entries, Entry64[int]{key, bits, len(entries)})
+		}
+	}
+	return entries
+}
+
+func TestInsertDedup(t *testing.T) {
+	same := func(covering, covered string) bool { return covering == covered }
+	r := New64[string]()
+	r.InsertDedup([]Entry64[string]{
+		{0x0A01020000000000, 24, "a"}, // covered by the /16 with the same value
+		{0x0A01030000000000, 24, "b"}, // covered by the /16, but another value
+		{0x0A01000000000000, 16, "a"},
+		{0xC0A8010000000000, 24, "a"}, // nothing covers it
+	}, same)
+	if r.Len() != 3 || r.Contains(0x0A01020000000000, 24) {
+		t.Logf("Expected the redundant /24 to be skipped, got %s\n", r)
+		t.Fail()
+	}
+	for _, e := range []Entry64[string]{{0x0A01000000000000, 16, "a"}, {0x0A01030000000000, 24, "b"}, {0xC0A8010000000000, 24, "a"}} {
+		if x, ok := r.ExactMatch(e.Key, e.Bits); !ok || x.Value != e.Value {
+			t.Logf("Expected %016x/%d to be kept\n", e.Key, e.Bits)
+			t.Fail()
+		}
+	}
+	// Keys already in the tree are taken into account.
+	r.InsertDedup([]Entry64[string]{{0x0A01030400000000, 32, "b"}, {0x0A01030500000000, 32, "c"}}, same)
+	if r.Contains(0x0A01030400000000, 32) || !r.Contains(0x0A01030500000000, 32) {
+		t.Logf("Expected only the /32 with another value to be inserted\n")
+		t.Fail()
+	}
+	// The key of the covering /8 has host bits set, so it is larger than the
+	// key of the /16 it covers, it is still inserted first.
+	d := New64[string]()
+	d.InsertDedup([]Entry64[string]{
+		{0x0A01000000000000, 16, "a"},
+		{0x0AFF000000000000, 8, "a"},
+	}, same)
+	if d.Len() != 1 || d.Contains(0x0A01000000000000, 16) {
+		t.Logf("Expected the /16 under the /8 with host bits to be skipped, got %s\n", d)
+		t.Fail()
+	}
+}
+
+func TestInsertBatch(t *testing.T) {
+	entries := randomEntries64(2000)
+	entries = append(entries, Entry64[int]{entries[0].Key, entries[0].Bits, -1}) // last one wins
+	r := New64[int]()
+	for _, e := range entries {
+		r.Insert(e.Key, e.Bits, e.Value)
+	}
+	b := New64[int]()
+	b.InsertBatch(entries)
+	if entries[0].Value != 0 {
+		t.Logf("Expected entries not to be modified\n")
+		t.Fail()
+	}
+	if !reflect.DeepEqual(r.Prefixes(), b.Prefixes()) || !reflect.DeepEqual(r.Values(), b.Values()) {
+		t.Logf("Expected the same keys and values\n")
+		t.Fail()
+	}
+	if x := b.Find(entries[0].Key, entries[0].Bits); x == nil || x.Value != -1 {
+		t.Logf("Expected the last entry to win\n")
+		t.Fail()
+	}
+}
+
+func BenchmarkInsertLoop(b *testing.B) {
+	entries := randomEntries64(100000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := New64[int]()
+		for _, e := range entries {
+			r.Insert(e.Key, e.Bits, e.Value)
+		}
+	}
+}
+
+func BenchmarkInsertBatch(b *testing.B) {
+	entries := randomEntries64(100000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		New64[int]().InsertBatch(entries)
+	}
+}
+
+func TestEqual(t *testing.T) {
+	eq := func(a, b int) bool { return a == b }
+	entries := randomEntries64(500)
+	r1, r2 := New64[int](), New64[int]()
+	for i := range entries {
+		r1.Insert(entries[i].Key, entries[i].Bits, entries[i].Value)
+		e := entries[len(entries)-1-i]
+		r2.Insert(e.Key, e.Bits, e.Value)
+	}
+	if !r1.Equal(r2, eq) || !r2.Equal(r1, eq) {
+		t.Logf("Expected trees with the same entries to be equal\n")
+		t.Fail()
+	}
+	r2.Insert(entries[42].Key, entries[42].Bits, -1)
+	if r1.Equal(r2, eq) {
+		t.Logf("Expected trees with a different value not to be equal\n")
+		t.Fail()
+	}
+	if !New64[int]().Equal(New64[int](), eq) {
+		t.Logf("Expected empty trees to be equal\n")
+		t.Fail()
+	}
+}
+
+func TestMerge(t *testing.T) {
+	r1, r2 := New64[uint32](), New64[uint32]()
+	addRoute64(t, r1, "10.0.0.0/8", 1)
+	addRoute64(t, r1, "192.168.0.0/16", 2)
+	addRoute64(t, r2, "172.16.0.0/12", 3)
+	addRoute64(t, r2, "192.168.0.0/16", 4)
+	r1.Merge(r2, func(existing, incoming uint32) uint32 { return max(existing, incoming) })
+	if r1.Len() != 3 || r2.Len() != 2 {
+		t.Logf("Expected 3 and 2 keys, got %d and %d\n", r1.Len(), r2.Len())
+		t.Fail()
+	}
+	if !reflect.DeepEqual(r1.Values(), []uint32{1, 3, 4}) {
+		t.Logf("Expected values [1 3 4], got %v\n", r1.Values())
+		t.Fail()
+	}
+	r1.Merge(r2, func(existing, _ uint32) uint32 { return existing })
+	if !reflect.DeepEqual(r1.Values(), []uint32{1, 3, 4}) {
+		t.Logf("Expected values [1 3 4], got %v\n", r1.Values())
+		t.Fail()
+	}
+}
+
+func TestMap64(t *testing.T) {
+	r := New64[string]()
+	r.Insert(0x0A00000000000000, 8, "eth0")
+	r.Insert(0x0A01000000000000, 16, "eth1")
+	r.Insert(0xC0A8010000000000, 24, "eth10")
+	m := Map64(r, func(s string) int { return len(s) })
+	if !reflect.DeepEqual(r.Prefixes(), m.Prefixes()) {
+		t.Logf("Expected the same prefixes\n")
+		t.Fail()
+	}
+	if !reflect.DeepEqual(m.Values(), []int{4, 4, 5}) || m.Len() != 3 {
+		t.Logf("Expected values [4 4 5], got %v\n", m.Values())
+		t.Fail()
+	}
+}
+
+func TestDiff64(t *testing.T) {
+	eq := func(a, b string) bool { return a == b }
+	old := New64[string]()
+	old.Insert(0x0A00000000000000, 8, "ten")
+	old.Insert(0x0A01000000000000, 16, "ten-one")
+	old.Insert(0xC0A8000000000000, 16, "private")
+	cur := old.Clone()
+	if added, removed, changed := Diff64(old, cur, eq); added != nil || removed != nil || changed != nil {
+		t.Logf("Expected no differences, got %v %v %v\n", added, removed, changed)
+		t.Fail()
+	}
+
+	cur.Remove(0x0A01000000000000, 16)
+	cur.Insert(0x0A00000000000000, 16, "ten-zero")
+	cur.Insert(0xC0A8000000000000, 16, "home")
+	cur.Insert(0, 0, "default")
+	added, removed, changed := Diff64(old, cur, eq)
+	want := [][]Entry64[string]{
+		{{0, 0, "default"}, {0x0A00000000000000, 16, "ten-zero"}},
+		{{0x0A01000000000000, 16, "ten-one"}},
+		{{0xC0A8000000000000, 16, "home"}},
+	}
+	for i, got := range [][]Entry64[string]{added, removed, changed} {
+		if !slices.Equal(got, want[i]) {
+			t.Logf("Expected %v, got %v\n", want[i], got)
+			t.Fail()
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	r := New64[uint32]()
+	addRoute64(t, r, "10.0.0.0/8", 1)
+	addRoute64(t, r, "10.1.1.0/24", 2)
+	addRoute64(t, r, "192.168.1.0/24", 3)
+	addRoute64(t, r, "192.168.2.0/24", 4)
+	f := r.Filter(func(_ uint64, bits int, _ uint32) bool { return bits == 24 })
+	if !reflect.DeepEqual(f.Values(), []uint32{2, 3, 4}) || f.Len() != 3 {
+		t.Logf("Expected values [2 3 4], got %v\n", f.Values())
+		t.Fail()
+	}
+	f = r.Filter(func(_ uint64, _ int, v uint32) bool { return v%2 == 1 })
+	if !reflect.DeepEqual(f.Values(), []uint32{1, 3}) {
+		t.Logf("Expected values [1 3], got %v\n", f.Values())
+		t.Fail()
+	}
+	if r.Len() != 4 {
+		t.Logf("Expected the original tree to be untouched\n")
+		t.Fail()
+	}
+}
+
+func TestDeleteFunc(t *testing.T) {
+	entries := randomEntries64(1000)
+	r := New64[int]()
+	for _, e := range entries {
+		r.Insert(e.Key, e.Bits, e.Value)
+	}
+	if n := r.DeleteFunc(func(_ uint64, _ int, v int) bool { return v%2 == 0 }); n != 500 {
+		t.Logf("Expected 500 keys removed, got %d\n", n)
+		t.Fail()
+	}
+	if r.Len() != 500 || len(r.Keys()) != 500 {
+		t.Logf("Expected 500 keys left, got %d\n", r.Len())
+		t.Fail()
+	}
+	for _, e := range entries {
+		if r.Contains(e.Key, e.Bits) != (e.Value%2 == 1) {
+			t.Logf("Expected %064b/%d to be present: %t\n", e.Key, e.Bits, e.Value%2 == 1)
+			t.Fail()
+		}
+	}
+	if n := r.DeleteFunc(func(uint64, int, int) bool { return false }); n != 0 {
+		t.Logf("Expected no keys removed, got %d\n", n)
+		t.Fail()
+	}
+}
+
+func TestShortestPrefixMatch(t *testing.T) {
+	routes := []string{"10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24"}
+	testips := map[string]uint32{
+		"10.1.2.3":    8,
+		"10.2.0.1":    8,
+		"11.0.0.1":    0,
+		"192.168.0.1": 0,
+	}
+	for _, order := range [][]int{{0, 1, 2}, {2, 1, 0}} {
+		r := New64[uint32]()
+		for _, i := range order {
+			addRoute64(t, r, routes[i], uint32(8*(i+1)))
+		}
+		for ip, asn := range testips {
+			n, _ := ipToUint64(t, &net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(32, 32)})
+			x, ok := r.ShortestPrefixMatch(n)
+			if ok != (asn != 0) || ok && x.Value != asn {
+				t.Logf("Expected %d, got %v for %s\n", asn, x, ip)
+				t.Fail()
+			}
+		}
+		// A route covering half of the address space acts as the default.
+		addRoute64(t, r, "0.0.0.0/1", 1)
+		for ip := range testips {
+			n, _ := ipToUint64(t, &net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(32, 32)})
+			x, ok := r.ShortestPrefixMatch(n)
+			want := uint32(1)
+			if ip == "192.168.0.1" {
+				want = 0
+			}
+			if ok != (want != 0) || ok && x.Value != want {
+				t.Logf("Expected %d, got %v for %s\n", want, x, ip)
+				t.Fail()
+			}
+		}
+	}
+}
+
+func TestFindAll(t *testing.T) {
+	r := New64[uint32]()
+	for _, route := range []string{"10.1.2.0/24", "10.0.0.0/8", "10.1.0.0/16", "10.2.0.0/16"} {
+		_, ipnet, _ := net.ParseCIDR(route)
+		_, bits := ipToUint64(t, ipnet)
+		addRoute64(t, r, route, uint32(bits))
+	}
+	testips := map[string][]uint32{
+		"10.1.2.3": {24, 16, 8},
+		"10.1.3.1": {16, 8},
+		"10.3.0.1": {8},
+	}
+	for ip, want := range testips {
+		n, _ := ipToUint64(t, &net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(32, 32)})
+		var got []uint32
+		for _, x := range r.FindAll(n) {
+			got = append(got, x.Value)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Logf("Expected %v, got %v for %s\n", want, got, ip)
+			t.Fail()
+		}
+	}
+	n, _ := ipToUint64(t, &net.IPNet{IP: net.ParseIP("192.168.0.1"), Mask: net.CIDRMask(32, 32)})
+	if x := r.FindAll(n); x == nil || len(x) != 0 {
+		t.Logf("Expected an empty slice, got %v\n", x)
+		t.Fail()
+	}
+}
+
+func TestGetN(t *testing.T) {
+	r := New64[uint32]()
+	for _, route := range []string{"10.1.2.0/24", "10.0.0.0/8", "10.1.0.0/16", "10.2.0.0/16"} {
+		_, ipnet, _ := net.ParseCIDR(route)
+		_, bits := ipToUint64(t, ipnet)
+		addRoute64(t, r, route, uint32(bits))
+	}
+	n, _ := ipToUint64(t, &net.IPNet{IP: net.ParseIP("10.1.2.3"), Mask: net.CIDRMask(32, 32)})
+	tests := map[int][]uint32{
+		-1: {},
+		0:  {},
+		2:  {24, 16},
+		3:  {24, 16, 8},
+		5:  {24, 16, 8},
+	}
+	for count, want := range tests {
+		got := []uint32{}
+		for _, x := range r.GetN(n, count) {
+			got = append(got, x.Value)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Logf("Expected %v, got %v for a count of %d\n", want, got, count)
+			t.Fail()
+		}
+	}
+	n, _ = ipToUint64(t, &net.IPNet{IP: net.ParseIP("192.168.0.1"), Mask: net.CIDRMask(32, 32)})
+	if x := r.GetN(n, 2); x == nil || len(x) != 0 {
+		t.Logf("Expected an empty slice, got %v\n", x)
+		t.Fail()
+	}
+
+	// Nested keys inserted in random order, GetN must agree with FindAll.
+	rnd := rand.New(rand.NewPCG(100, 100))
+	for _, q := range []*Radix64[uint32]{New64[uint32](), NewLazy64[uint32]()} {
+		q.Insert(0, 0, 0)
+		for i := 0; i < 2000; i++ {
+			q.Insert(rnd.Uint64()&^(1<<52-1), 1+rnd.IntN(20), uint32(i))
+		}
+		for i := 0; i < 500; i++ {
+			n := rnd.Uint64()
+			all := q.FindAll(n)
+			for _, count := range []int{1, 2, 5, 30} {
+				got := q.GetN(n, count)
+				if !slices.Equal(got, all[:min(count, len(all))]) {
+					t.Logf("Expected the first %d of FindAll for %064b\n", count, n)
+					t.Fail()
+				}
+			}
+		}
+	}
+}
+
+func parentsOK64[T any](r *Radix64[T]) bool {
+	ok := true
+	r.Do(func(r1 *Radix64[T], _ int) {
+		for _, b := range r1.branch {
+			if b != nil && b.parent != r1.generic() {
+				ok = false
+			}
+		}
+	})
+	return ok
+}
+
+func TestNewLazy64(t *testing.T) {
+	entries := randomEntries64(1000)
+	eager, lazy := New64[int](), NewLazy64[int]()
+	if !lazy.Empty() || !lazy.Leaf() {
+		t.Logf("Expected a lazy tree to be a single empty node\n")
+		t.Fail()
+	}
+	same := func(what string) {
+		if !lazy.Equal(eager, func(a, b int) bool { return a == b }) || lazy.Len() != eager.Len() {
+			t.Logf("Expected the same keys %s\n", what)
+			t.Fail()
+		}
+		rnd := rand.New(rand.NewPCG(79, 79))
+		for i := 0; i < 1000; i++ {
+			n := rnd.Uint64()
+			if i%2 == 0 {
+				e := entries[rnd.IntN(len(entries))]
+				n = e.Key | n>>e.Bits
+			}
+			v, ok := eager.Get(n)
+			if v1, ok1 := lazy.Get(n); v1 != v || ok1 != ok {
+				t.Logf("Expected %d (%t) for %016x %s, got %d (%t)\n", v, ok, n, what, v1, ok1)
+				t.Fail()
+				return
+			}
+		}
+		if !parentsOK64(lazy) {
+			t.Logf("Expected consistent parents %s\n", what)
+			t.Fail()
+		}
+	}
+	for _, e := range entries {
+		eager.Insert(e.Key, e.Bits, e.Value)
+		lazy.Insert(e.Key, e.Bits, e.Value)
+	}
+	same("after insert")
+	for _, e := range entries[:500] {
+		eager.Remove(e.Key, e.Bits)
+		lazy.Remove(e.Key, e.Bits)
+	}
+	same("after remove")
+	for _, e := range entries[500:] {
+		lazy.Remove(e.Key, e.Bits)
+	}
+	if !lazy.Empty() {
+		t.Logf("Expected an empty tree, got %d keys\n", lazy.Len())
+		t.Fail()
+	}
+}
+
+// Keeps the trees created in the benchmarks on the heap.
+var sink64 *Radix64[int]
+
+func BenchmarkNew64(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sink64 = New64[int]()
+	}
+}
+
+func BenchmarkNewLazy64(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sink64 = NewLazy64[int]()
+	}
+}
+
+func TestRemoveNotify(t *testing.T) {
+	// The left leaning tree of TestStats, 0x03/8 is a leaf below 0x02/8.
+	r := New64[uint64]()
+	for k := uint64(0); k < 4; k++ {
+		r.Insert(k<<56, 8, k)
+	}
+	r.Insert(0, 0, 42)
+	var gone []uint64
+	onGone := func(r1 *Radix64[uint64]) { gone = append(gone, r1.Key()>>56) }
+
+	// Removing 0x02/8 clears its node, and 0x03/8 moves up into it.
+	if x := r.RemoveNotify(0x02<<56, 8, onGone); x == nil || x.Value != 2 {
+		t.Logf("Expected to remove 0x02/8\n")
+		t.Fail()
+	}
+	if !slices.Equal(gone, []uint64{0x02, 0x03}) {
+		t.Logf("Expected the nodes of 0x02/8 and 0x03/8 to be gone, got %x\n", gone)
+		t.Fail()
+	}
+	if x := r.Find(0x03<<56, 8); x == nil || x.Value != 3 {
+		t.Logf("Expected to find 0x03/8 in its new node\n")
+		t.Fail()
+	}
+
+	gone = nil
+	r.RemoveNotify(0x03<<56, 8, onGone)
+	if !slices.Equal(gone, []uint64{0x03}) {
+		t.Logf("Expected the node of 0x03/8 to be gone, got %x\n", gone)
+		t.Fail()
+	}
+	gone = nil
+	if x := r.RemoveNotify(0, 0, onGone); x == nil || x.Value != 42 || len(gone) != 1 {
+		t.Logf("Expected the default route to be gone\n")
+		t.Fail()
+	}
+	gone = nil
+	if x := r.RemoveNotify(0x03<<56, 8, onGone); x != nil || gone != nil {
+		t.Logf("Expected nothing to remove\n")
+		t.Fail()
+	}
+	if r.Len() != 2 || !parentsOK64(r) {
+		t.Logf("Expected 2 keys in a consistent tree, got %d\n", r.Len())
+		t.Fail()
+	}
+}
+
+func TestCompact(t *testing.T) {
+	// The left leaning tree of TestStats, removing 0x01/8 leaves a node
+	// without a key above 0x02/8, and removing 0x03/8 makes 0x02/8 a leaf.
+	r := New64[uint64]()
+	for k := uint64(0); k < 4; k++ {
+		r.Insert(k<<56, 8, k)
+	}
+	r.Remove(0x01<<56, 8)
+	r.Remove(0x03<<56, 8)
+	before := r.Stats().Nodes
+	r.Compact()
+	if after := r.Stats().Nodes; after != before-1 {
+		t.Logf("Expected %d nodes, got %d\n", before-1, after)
+		t.Fail()
+	}
+	if x := r.Find(0x02<<56, 8); x == nil || x.Value != 0x02 || r.Len() != 2 {
+		t.Logf("Expected to find 0x02/8 after compacting\n")
+		t.Fail()
+	}
+
+	entries := randomEntries64(1000)
+	r = New64[uint64]()
+	for i, e := range entries {
+		r.Insert(e.Key, e.Bits, uint64(i))
+	}
+	for i, e := range entries {
+		if i%3 != 0 {
+			r.Remove(e.Key, e.Bits)
+		}
+	}
+	rnd := rand.New(rand.NewPCG(77, 77))
+	addrs := make([]uint64, 1000)
+	for i := range addrs {
+		addrs[i] = rnd.Uint64()
+		if i%2 == 0 {
+			e := entries[rnd.IntN(len(entries))]
+			addrs[i] = e.Key | addrs[i]>>e.Bits
+		}
+	}
+	want, keys := r.GetBatch(addrs), r.Entries()
+	before = r.Stats().Nodes
+	r.Compact()
+	if after := r.Stats().Nodes; after >= before {
+		t.Logf("Expected fewer than %d nodes, got %d\n", before, after)
+		t.Fail()
+	}
+	if !slices.Equal(r.GetBatch(addrs), want) || !slices.Equal(r.Entries(), keys) || r.Len() != len(keys) {
+		t.Logf("Expected the same answers after compacting\n")
+		t.Fail()
+	}
+	for _, e := range keys {
+		if x := r.Find(e.Key, e.Bits); x == nil || x.Bits() != e.Bits {
+			t.Logf("Expected to find %016x/%d after compacting\n", e.Key, e.Bits)
+			t.Fail()
+		}
+	}
+	if !parentsOK64(r) {
+		t.Logf("Expected consistent parents after compacting\n")
+		t.Fail()
+	}
+}
+
+func TestPruneParents(t *testing.T) {
+	entries := randomEntries64(300)
+	for _, order := range []string{"forward", "backward", "deepest"} {
+		r := New64[int]()
+		for _, e := range entries {
+			r.Insert(e.Key, e.Bits, e.Value)
+		}
+		del := slices.Clone(entries)
+		switch order {
+		case "backward":
+			slices.Reverse(del)
+		case "deepest":
+			slices.SortStableFunc(del, func(a, b Entry64[int]) int { return b.Bits - a.Bits })
+		}
+		for i, e := range del {
+			r.Remove(e.Key, e.Bits)
+			if !parentsOK64(r) {
+				t.Logf("Expected consistent parents after %d removals (%s)\n", i+1, order)
+				t.Fail()
+				break
+			}
+		}
+	}
+
+	r := New32[uint32]()
+	for _, route := range []string{"10.0.0.0/8", "10.20.0.0/14", "10.21.0.0/16", "10.21.1.0/24", "10.21.1.128/25"} {
+		addRoute(t, r, route, 1)
+	}
+	for _, route := range []string{"10.20.0.0/14", "10.0.0.0/8", "10.21.0.0/16", "10.21.1.0/24"} {
+		_, ipnet, _ := net.ParseCIDR(route)
+		r.Remove(ipToUint(t, ipnet))
+		r.Do(func(r1 *Radix32[uint32], _ int) {
+			for _, b := range r1.branch {
+				if b != nil && b.parent != r1.generic() {
+					t.Logf("Expected consistent parents after removal of %s\n", route)
+					t.Fail()
+				}
+			}
+		})
+	}
+}
+
+func TestDefaultRoute(t *testing.T) {
+	r := New64[uint32]()
+	addRoute64(t, r, "10.0.0.0/8", 8)
+	// The key of the default route is not used in matching.
+	r.Insert(0xDEADBEEF00000000, 0, 1)
+	if r.Len() != 2 {
+		t.Logf("Expected 2 keys, got %d\n", r.Len())
+		t.Fail()
+	}
+	for _, n := range []uint64{0, 0x0A01020300000000, 0xC0A8000100000000, 0xFFFFFFFFFFFFFFFF} {
+		want := uint32(1)
+		if n>>56 == 10 {
+			want = 8
+		}
+		if x := r.Find(n, 32); x == nil || x.Value != want {
+			t.Logf("Expected %d, got %v for %064b\n", want, x, n)
+			t.Fail()
+		}
+		if x, ok := r.LongestPrefixMatch(n); !ok || x.Value != want {
+			t.Logf("Expected %d, got %v for %064b\n", want, x, n)
+			t.Fail()
+		}
+		if x, ok := r.ShortestPrefixMatch(n); !ok || x.Value != 1 {
+			t.Logf("Expected the default route, got %v for %064b\n", x, n)
+			t.Fail()
+		}
+	}
+	if x := r.Find(0x1234, 0); x == nil || x.Value != 1 || x.Bits() != 0 {
+		t.Logf("Expected to find the default route\n")
+		t.Fail()
+	}
+	c := r.Clone()
+	if !reflect.DeepEqual(c.Prefixes(), r.Prefixes()) || c.Prefixes()[0].Bits != 0 {
+		t.Logf("Expected the default route to be listed and cloned\n")
+		t.Fail()
+	}
+	if x := r.Remove(0, 0); x == nil || x.Value != 1 {
+		t.Logf("Expected to remove the default route\n")
+		t.Fail()
+	}
+	if x := r.Find(0xC0A8000100000000, 32); x != nil || r.Len() != 1 {
+		t.Logf("Expected no match after removing the default route, got %v\n", x)
+		t.Fail()
+	}
+	if x := r.Remove(0, 0); x != nil {
+		t.Logf("Expected nothing to remove\n")
+		t.Fail()
+	}
+}
+
+func TestDefaultRouteKey(t *testing.T) {
+	r := New64[int]()
+	r.Insert(0x0A00000000000000, 8, 8)
+	r.Insert(0x0000000100000000, 32, 32)
+	// The host bits of the default route are not stored.
+	r.Insert(0xDEADBEEF00000000, 0, 1)
+	if x := r.Find(0, 0); x == nil || x.Key() != 0 {
+		t.Logf("Expected the default route to have key 0, got %v\n", x)
+		t.Fail()
+	}
+	if x := r.Insert(0xFFFFFFFFFFFFFFFF, 0, 2); x.Key() != 0 || r.Len() != 3 {
+		t.Logf("Expected the overwritten default route to keep key 0, got %016x\n", x.Key())
+		t.Fail()
+	}
+	keys := r.Keys()
+	if !slices.IsSorted(keys) || keys[0] != 0 {
+		t.Logf("Expected sorted keys starting with the default route, got %x\n", keys)
+		t.Fail()
+	}
+	it := r.Iterator()
+	it.SeekKey(1)
+	if !it.Next() || it.Key() != 0x0000000100000000 || it.Bits() != 32 {
+		t.Logf("Expected SeekKey(1) to move to the /32\n")
+		t.Fail()
+	}
+}
+
+func TestParentRoot(t *testing.T) {
+	r := New64[int]()
+	var deep *Radix64[int]
+	for _, e := range randomEntries64(200) {
+		if x := r.Insert(e.Key, e.Bits, e.Value); deep == nil || x.Bits() > deep.Bits() {
+			deep = x
+		}
+	}
+	if deep.Root() != r || r.Root() != r {
+		t.Logf("Expected Root to return the tree created by New64\n")
+		t.Fail()
+	}
+	r.Do(func(r1 *Radix64[int], _ int) {
+		if (r1.Parent() == nil) != (r1 == r) {
+			t.Logf("Expected Parent to be nil only for the root\n")
+			t.Fail()
+		}
+	})
+}
+
+func TestBranch(t *testing.T) {
+	r := New64[int]()
+	r.Insert(0x8000000000000000, 1, 1)
+	r.Insert(0x4000000000000000, 2, 2)
+	r.Insert(0xC000000000000000, 2, 3)
+	left, right := r.Children()
+	if left != r.Branch(0) || right != r.Branch(1) {
+		t.Logf("Expected Children to return both branches\n")
+		t.Fail()
+	}
+	if r.Bits() != 1 || r.Value != 1 {
+		t.Logf("Expected the /1 in the root, got %064b/%d\n", r.Key(), r.Bits())
+		t.Fail()
+	}
+	if left.Key() != 0x4000000000000000 || left.Value != 2 || !left.Leaf() {
+		t.Logf("Expected the left branch to hold %064b/2\n", uint64(0x4000000000000000))
+		t.Fail()
+	}
+	if right.Key() != 0xC000000000000000 || right.Value != 3 || !right.Leaf() {
+		t.Logf("Expected the right branch to hold %064b/2\n", uint64(0xC000000000000000))
+		t.Fail()
+	}
+	defer func() {
+		if recover() == nil {
+			t.Logf("Expected Branch(2) to panic\n")
+			t.Fail()
+		}
+	}()
+	r.Branch(2)
+}
+
+func TestRadixGeneric(t *testing.T) {
+	rnd := rand.New(rand.NewPCG(40, 40))
+	r32, g32 := New32[int](), New[uint32, int]()
+	r64, g64 := New64[int](), New[uint64, int]()
+	for i := 0; i < 1000; i++ {
+		bits := 1 + rnd.IntN(32)
+		k := rnd.Uint32() & uint32(mask32<<(bitSize32-bits))
+		r32.Insert(k, bits, i)
+		g32.Insert(k, bits, i)
+		r64.Insert(uint64(k)<<32, bits, i)
+		g64.Insert(uint64(k)<<32, bits, i)
+	}
+	if r32.Len() != g32.Len() || r64.Len() != g64.Len() {
+		t.Logf("Expected the same number of keys\n")
+		t.Fail()
+	}
+	for i := 0; i < 1000; i++ {
+		bits := 1 + rnd.IntN(32)
+		k := rnd.Uint32()
+		x, y := r32.Find(k, bits), g32.Find(k, bits)
+		if (x == nil) != (y == nil) || x != nil && (x.Value != y.Value || x.Key() != y.Key() || x.Bits() != y.Bits()) {
+			t.Logf("Expected the same node for %032b/%d\n", k, bits)
+			t.Fail()
+		}
+		x64, y64 := r64.Find(uint64(k)<<32, bits), g64.Find(uint64(k)<<32, bits)
+		if (x64 == nil) != (y64 == nil) || x64 != nil && (x64.Value != y64.Value || x64.Key() != y64.Key()) {
+			t.Logf("Expected the same node for %064b/%d\n", uint64(k)<<32, bits)
+			t.Fail()
+		}
+	}
+
+	// VLAN tags are 12 bits, stored left-aligned in an uint16.
+	vlan := New[uint16, string]()
+	vlan.Insert(100<<4, 12, "office")
+	vlan.Insert(0x800<<4, 1, "lab")
+	vlan.Insert(200<<4, 12, "guest")
+	if x := vlan.Find(100<<4, 12); x == nil || x.Value != "office" {
+		t.Logf("Expected to find VLAN 100\n")
+		t.Fail()
+	}
+	if x := vlan.Find(0x900<<4, 12); x == nil || x.Value != "lab" {
+		t.Logf("Expected VLAN 2304 to fall under the lab\n")
+		t.Fail()
+	}
+	if x := vlan.Remove(200<<4, 12); x == nil || x.Value != "guest" || vlan.Len() != 2 {
+		t.Logf("Expected to remove VLAN 200\n")
+		t.Fail()
+	}
+
+	// An uint16 key has 16 bits at most.
+	if _, err := vlan.InsertE(0x1234, 20, "bad"); !errors.Is(err, ErrBits) {
+		t.Logf("Expected ErrBits, got %v\n", err)
+		t.Fail()
+	}
+	if vlan.Find(0x1234, 20) != nil || vlan.Remove(0x1234, 20) != nil || vlan.Len() != 2 {
+		t.Logf("Expected nothing to be found for 20 bits\n")
+		t.Fail()
+	}
+	func() {
+		defer func() {
+			if err, _ := recover().(error); !errors.Is(err, ErrBits) {
+				t.Logf("Expected an ErrBits panic, got %v\n", err)
+				t.Fail()
+			}
+		}()
+		New[uint16, int]().Insert(0x1234, 20, 1)
+	}()
+}
+
+func TestRadixDefault(t *testing.T) {
+	// A key with zero bits is the default route, it is not lost.
+	r := New[uint16, int]()
+	r.Insert(5, 0, 9)
+	if r.Len() != 1 {
+		t.Logf("Expected 1 key, got %d\n", r.Len())
+		t.Fail()
+	}
+	if x := r.Find(5, 0); x == nil || x.Value != 9 || x.Bits() != 0 {
+		t.Logf("Expected to find the default route\n")
+		t.Fail()
+	}
+	r.Insert(0x1200, 8, 1)
+	if x := r.Find(0x1234, 16); x == nil || x.Value != 1 {
+		t.Logf("Expected 0x1234/16 to fall under 0x1200/8\n")
+		t.Fail()
+	}
+	if x := r.Find(0x3400, 16); x == nil || x.Value != 9 {
+		t.Logf("Expected 0x3400/16 to fall under the default route\n")
+		t.Fail()
+	}
+	if x := r.Insert(7, 0, 10); x.Value != 10 || r.Len() != 2 {
+		t.Logf("Expected the default route to be overwritten, got %d keys\n", r.Len())
+		t.Fail()
+	}
+	if x := r.Remove(0, 0); x == nil || x.Value != 10 || r.Len() != 1 {
+		t.Logf("Expected to remove the default route\n")
+		t.Fail()
+	}
+	if x := r.Find(0x3400, 16); x != nil {
+		t.Logf("Expected nothing to cover 0x3400/16, got %v\n", x.Value)
+		t.Fail()
+	}
+}
+
+func TestNewWithPool64(t *testing.T) {
+	p := &sync.Pool{}
+	entries := randomEntries64(500)
+	r, q := New64[int](), NewWithPool64[int](p)
+	for round := 0; round < 3; round++ {
+		for _, e := range entries {
+			r.Insert(e.Key, e.Bits, e.Value)
+			q.Insert(e.Key, e.Bits, e.Value)
+		}
+		if !r.Equal(q, func(a, b int) bool { return a == b }) {
+			t.Logf("Expected the same entries in round %d\n", round)
+			t.Fail()
+		}
+		for _, e := range entries[:400] {
+			r.Remove(e.Key, e.Bits)
+			q.Remove(e.Key, e.Bits)
+		}
+		if !r.Equal(q, func(a, b int) bool { return a == b }) {
+			t.Logf("Expected the same entries after removal in round %d\n", round)
+			t.Fail()
+		}
+	}
+	// Nodes in the pool are zeroed.
+	for i := 0; i < 100; i++ {
+		x, ok := p.Get().(*Radix[uint64, int])
+		if !ok {
+			break
+		}
+		if x.Value != 0 || x.bits != 0 || x.parent != nil || !x.Leaf() {
+			t.Logf("Expected a zeroed node from the pool\n")
+			t.Fail()
+			break
+		}
+	}
+}
+
+func benchmarkChurn(b *testing.B, r *Radix64[int]) {
+	entries := randomEntries64(10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, e := range entries {
+			r.Insert(e.Key, e.Bits, e.Value)
+		}
+		for _, e := range entries {
+			r.Delete(e.Key, e.Bits)
+		}
+	}
+}
+
+func BenchmarkChurnNew64(b *testing.B) { benchmarkChurn(b, New64[int]()) }
+
+func BenchmarkChurnNewWithPool64(b *testing.B) {
+	benchmarkChurn(b, NewWithPool64[int](&sync.Pool{}))
+}
+
+func TestString(t *testing.T) {
+	r := New64[string]()
+	r.Insert(0x8000000000000000, 1, "one")
+	r.Insert(0x4000000000000000, 2, "two")
+	r.Insert(0xC000000000000000, 2, "three")
+	want := `root 8000000000000000/1: one
+  0 4000000000000000/2: two
+  1 c000000000000000/2: three
+`
+	if got := r.String(); got != want {
+		t.Logf("Expected\n%s\ngot\n%s\n", want, got)
+		t.Fail()
+	}
+}
+
+func TestCountFunc(t *testing.T) {
+	r := New64[uint32]()
+	all := func(uint64, int, uint32) bool { return true }
+	if n := r.CountFunc(all); n != 0 {
+		t.Logf("Expected 0 keys in an empty tree, got %d\n", n)
+		t.Fail()
+	}
+	addRoute64(t, r, "10.0.0.0/8", 1)
+	addRoute64(t, r, "10.1.1.0/24", 2)
+	addRoute64(t, r, "192.168.1.0/24", 3)
+	addRoute64(t, r, "192.168.2.0/24", 4)
+	if n := r.CountFunc(func(_ uint64, bits int, _ uint32) bool { return bits == 24 }); n != 3 {
+		t.Logf("Expected 3 /24 keys, got %d\n", n)
+		t.Fail()
+	}
+	if n := r.CountFunc(func(_ uint64, _ int, v uint32) bool { return v > 2 }); n != 2 {
+		t.Logf("Expected 2 keys with a value larger than 2, got %d\n", n)
+		t.Fail()
+	}
+	if n := r.CountFunc(all); n != r.Len() {
+		t.Logf("Expected %d keys, got %d\n", r.Len(), n)
+		t.Fail()
+	}
+	if a := testing.AllocsPerRun(10, func() { r.CountFunc(all) }); a != 0 {
+		t.Logf("Expected no allocations, got %v\n", a)
+		t.Fail()
+	}
+}
+
+func TestRange(t *testing.T) {
+	r := New64[uint32]()
+	for i, route := range []string{"8.8.8.0/24", "10.0.0.0/8", "10.1.0.0/16", "10.255.255.0/24", "11.0.0.0/8", "192.168.1.0/24"} {
+		addRoute64(t, r, route, uint32(i))
+	}
+	collect := func(lo, hi uint64) []uint32 {
+		var values []uint32
+		r.Range(lo, hi, func(r1 *Radix64[uint32], _ int) { values = append(values, r1.Value) })
+		return values
+	}
+	tests := []struct {
+		lo, hi uint64
+		want   []uint32
+	}{
+		{0x0A00000000000000, 0x0AFFFFFFFFFFFFFF, []uint32{1, 2, 3}},
+		{0x0A01000000000000, 0x0B00000000000000, []uint32{2, 3, 4}}, // both bounds on stored keys
+		{0, mask64, []uint32{0, 1, 2, 3, 4, 5}},
+		{0x0C00000000000000, 0xC0A8000000000000, nil},
+		{0x0B00000000000000, 0x0A00000000000000, nil}, // lo > hi
+	}
+	for _, tc := range tests {
+		if got := collect(tc.lo, tc.hi); !reflect.DeepEqual(got, tc.want) {
+			t.Logf("Expected %v, got %v for [%016x, %016x]\n", tc.want, got, tc.lo, tc.hi)
+			t.Fail()
+		}
+	}
+}
+
+func TestDoWithBuffer(t *testing.T) {
+	r := New64[int]()
+	for _, e := range randomEntries64(500) {
+		r.Insert(e.Key, e.Bits, e.Value)
+	}
+	type visit struct {
+		r *Radix64[int]
+		i int
+	}
+	var want, got []visit
+	r.Do(func(r1 *Radix64[int], i int) { want = append(want, visit{r1, i}) })
+	buf := make([]*Radix64[int], 0, 16) // too small, grows
+	r.DoWithBuffer(buf, func(r1 *Radix64[int], i int) { got = append(got, visit{r1, i}) })
+	if !reflect.DeepEqual(want, got) {
+		t.Logf("Expected the same nodes in the same order as Do\n")
+		t.Fail()
+	}
+	buf = make([]*Radix64[int], 0, r.Stats().Nodes)
+	if a := testing.AllocsPerRun(10, func() { r.DoWithBuffer(buf, func(*Radix64[int], int) {}) }); a != 0 {
+		t.Logf("Expected no allocations, got %v\n", a)
+		t.Fail()
+	}
+}
+
+func BenchmarkDo(b *testing.B) {
+	r := New64[int]()
+	for _, e := range randomEntries64(10000) {
+		r.Insert(e.Key, e.Bits, e.Value)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Do(func(*Radix64[int], int) {})
+	}
+}
+
+func BenchmarkDoWithBuffer(b *testing.B) {
+	r := New64[int]()
+	for _, e := range randomEntries64(10000) {
+		r.Insert(e.Key, e.Bits, e.Value)
+	}
+	buf := make([]*Radix64[int], 0, r.Stats().Nodes)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.DoWithBuffer(buf, func(*Radix64[int], int) {})
+	}
+}
+
+func TestExactMatch(t *testing.T) {
+	r := New64[uint32]()
+	addRoute64(t, r, "10.0.0.0/8", 8)
+	addRoute64(t, r, "10.1.0.0/16", 16)
+	tests := []struct {
+		route string
+		exact bool
+		find  uint32 // value returned by Find, 0 for nil
+	}{
+		{"10.1.0.0/16", true, 16}, // exact hit
+		{"10.0.0.0/8", true, 8},
+		{"10.1.2.0/24", false, 16}, // covering only
+		{"10.2.0.0/16", false, 8},
+		{"192.168.0.0/16", false, 0}, // nothing
+	}
+	for _, tc := range tests {
+		_, ipnet, _ := net.ParseCIDR(tc.route)
+		n, bits := ipToUint64(t, ipnet)
+		x, ok := r.ExactMatch(n, bits)
+		if ok != tc.exact || ok && (x.Key() != n || x.Bits() != bits) {
+			t.Logf("Expected exact match %t for %s\n", tc.exact, tc.route)
+			t.Fail()
+		}
+		y := r.Find(n, bits)
+		if (y == nil) != (tc.find == 0) || y != nil && y.Value != tc.find {
+			t.Logf("Expected Find to return %d for %s\n", tc.find, tc.route)
+			t.Fail()
+		}
+	}
+}
+
+func TestInsertNew(t *testing.T) {
+	r := New64[uint32]()
+	if x, ok := r.InsertNew(0x0A00000000000000, 8, 1); !ok || x.Value != 1 {
+		t.Logf("Expected the first insert to succeed\n")
+		t.Fail()
+	}
+	if x, ok := r.InsertNew(0x0A00000000000000, 8, 2); ok || x.Value != 1 {
+		t.Logf("Expected the second insert to fail and keep the value\n")
+		t.Fail()
+	}
+	if x, ok := r.InsertNew(0x0A01000000000000, 16, 3); !ok || x.Value != 3 || r.Len() != 2 {
+		t.Logf("Expected a key with other bits to be inserted\n")
+		t.Fail()
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	r := New64[uint32]()
+	addRoute64(t, r, "10.0.0.0/8", 8)
+	s := r.Snapshot()
+	addRoute64(t, r, "10.1.0.0/16", 16)
+	r.Find(0x0A00000000000000, 8).Value = 10
+	if s.Len() != 1 || s.Find(0x0A01000000000000, 16).Value != 8 {
+		t.Logf("Expected the snapshot to be unaffected by writes\n")
+		t.Fail()
+	}
+
+	// Readers use the published snapshot, while a single writer updates r.
+	var current atomic.Pointer[Radix64[uint32]]
+	current.Store(r.Snapshot())
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				if x, ok := current.Load().LongestPrefixMatch(0x0A01020300000000); !ok || x.Value != 16 {
+					t.Errorf("Expected to match 10.1.0.0/16")
+					return
+				}
+			}
+		}()
+	}
+	for i := uint64(0); i < 100; i++ {
+		r.Insert(0xC0A8000000000000|i<<40, 24, uint32(i))
+		current.Store(r.Snapshot())
+	}
+	wg.Wait()
+}
+
+func TestPrefixEqual64(t *testing.T) {
+	tests := []struct {
+		ka   uint64
+		ba   int
+		kb   uint64
+		bb   int
+		want bool
+	}{
+		{0x0A00000000000000, 8, 0x0A00000000000000, 8, true},
+		{0x0A00000500000000, 8, 0x0AFFFFFFFFFFFFFF, 8, true}, // only host bits differ
+		{0x0A00000000000000, 8, 0x0A00000000000000, 16, false},
+		{0x0A00000000000000, 8, 0x0B00000000000000, 8, false},
+		{0xFFFFFFFFFFFFFFFF, 0, 0, 0, true},
+		{0xFFFFFFFFFFFFFFFF, 64, 0xFFFFFFFFFFFFFFFE, 64, false},
+	}
+	for _, tc := range tests {
+		if got := PrefixEqual64(tc.ka, tc.ba, tc.kb, tc.bb); got != tc.want {
+			t.Logf("Expected %t for %016x/%d and %016x/%d\n", tc.want, tc.ka, tc.ba, tc.kb, tc.bb)
+			t.Fail()
+		}
+	}
+}
+
+func TestInsertNormalized(t *testing.T) {
+	if n := NormalizeKey64(0x0A00000500000000, 24); n != 0x0A00000000000000 {
+		t.Logf("Expected %016x, got %016x\n", uint64(0x0A00000000000000), n)
+		t.Fail()
+	}
+	if NormalizeKey64(mask64, 0) != 0 || NormalizeKey64(mask64, 64) != mask64 {
+		t.Logf("Expected the whole key to be cleared or kept\n")
+		t.Fail()
+	}
+
+	// Without normalization the host bits are kept in the stored key.
+	r := New64[int]()
+	r.Insert(0x0A00000500000000, 24, 1)
+	r.Insert(0x0A00000000000000, 24, 2)
+	if keys := r.Keys(); r.Len() != 1 || len(keys) != 1 || keys[0] != 0x0A00000000000000 {
+		t.Logf("Expected the last key inserted to be stored, got %x\n", keys)
+		t.Fail()
+	}
+	r.Insert(0x0A00000500000000, 24, 3)
+	if keys := r.Keys(); keys[0] != 0x0A00000500000000 {
+		t.Logf("Expected the host bits to be stored, got %x\n", keys)
+		t.Fail()
+	}
+
+	n := New64[int]()
+	n.InsertNormalized(0x0A00000500000000, 24, 1)
+	n.InsertNormalized(0x0A00000000000000, 24, 2)
+	n.InsertNormalized(0x0A0000FF00000000, 24, 3)
+	if keys := n.Keys(); n.Len() != 1 || keys[0] != 0x0A00000000000000 || n.Values()[0] != 3 {
+		t.Logf("Expected a single normalized key, got %x\n", keys)
+		t.Fail()
+	}
+}
+
+func TestForEach(t *testing.T) {
+	entries := randomEntries64(500)
+	r := New64[int]()
+	for _, e := range entries {
+		r.Insert(e.Key, e.Bits, e.Value)
+	}
+	r.Insert(0, 0, -1)
+	seen := make(map[Entry64[int]]int)
+	r.ForEach(func(key uint64, bits int, v int) { seen[Entry64[int]{key, bits, v}]++ })
+	if len(seen) != len(entries)+1 {
+		t.Logf("Expected %d keys, got %d\n", len(entries)+1, len(seen))
+		t.Fail()
+	}
+	for _, e := range append(entries, Entry64[int]{0, 0, -1}) {
+		if seen[e] != 1 {
+			t.Logf("Expected %064b/%d to be seen once, got %d\n", e.Key, e.Bits, seen[e])
+			t.Fail()
+		}
+	}
+}
+
+func TestRemovePrefix(t *testing.T) {
+	r := New64[uint32]()
+	routes := []string{"10.0.0.0/8", "10.1.0.0/16", "10.2.0.0/16", "10.1.1.0/24", "10.2.3.0/24", "10.255.255.0/24",
+		"11.0.0.0/8", "192.168.0.0/16", "192.168.1.0/24", "9.255.0.0/16"}
+	for i, route := range routes {
+		addRoute64(t, r, route, uint32(i))
+	}
+	if n := r.RemovePrefix(0x0A00000000000000, 8); n != 6 {
+		t.Logf("Expected 6 keys removed, got %d\n", n)
+		t.Fail()
+	}
+	if !reflect.DeepEqual(r.Values(), []uint32{9, 6, 7, 8}) || r.Len() != 4 {
+		t.Logf("Expected the unrelated keys to survive, got %v\n", r.Values())
+		t.Fail()
+	}
+	// 192.0.0.0/8 is not stored, but covers two keys.
+	if n := r.RemovePrefix(0xC000000000000000, 8); n != 2 || r.Len() != 2 {
+		t.Logf("Expected 2 keys removed, got %d\n", n)
+		t.Fail()
+	}
+	if n := r.RemovePrefix(0x0A00000000000000, 8); n != 0 {
+		t.Logf("Expected nothing to be removed, got %d\n", n)
+		t.Fail()
+	}
+	if n := r.RemovePrefix(0, 0); n != 2 || r.Len() != 0 {
+		t.Logf("Expected all keys to be removed, got %d\n", n)
+		t.Fail()
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	same := func(a, b uint32) (uint32, bool) { return a, a == b }
+	prefixes := func(r *Radix64[uint32]) []string {
+		var s []string
+		for _, p := range r.Prefixes() {
+			s = append(s, fmt.Sprintf("%s/%d", uintToIP(uint32(p.Key>>32)), p.Bits))
+		}
+		return s
+	}
+
+	r := New64[uint32]()
+	addRoute64(t, r, "10.0.0.0/25", 1)
+	addRoute64(t, r, "10.0.0.128/25", 1)
+	r.Aggregate(same)
+	if got := prefixes(r); !reflect.DeepEqual(got, []string{"10.0.0.0/24"}) || r.Values()[0] != 1 {
+		t.Logf("Expected [10.0.0.0/24], got %v\n", got)
+		t.Fail()
+	}
+
+	r = New64[uint32]()
+	for _, route := range []string{"10.0.0.0/26", "10.0.0.64/26", "10.0.0.128/26", "10.0.0.192/26", "10.0.1.0/24"} {
+		addRoute64(t, r, route, 1)
+	}
+	r.Aggregate(same)
+	if got := prefixes(r); !reflect.DeepEqual(got, []string{"10.0.0.0/23"}) || r.Len() != 1 {
+		t.Logf("Expected [10.0.0.0/23], got %v\n", got)
+		t.Fail()
+	}
+
+	r = New64[uint32]()
+	addRoute64(t, r, "10.0.0.0/25", 1)
+	addRoute64(t, r, "10.0.0.128/25", 2)
+	addRoute64(t, r, "10.0.2.0/24", 1) // no sibling
+	r.Aggregate(same)
+	if got := prefixes(r); !reflect.DeepEqual(got, []string{"10.0.0.0/25", "10.0.0.128/25", "10.0.2.0/24"}) {
+		t.Logf("Expected the keys to be left alone, got %v\n", got)
+		t.Fail()
+	}
+}
+
+func TestFromEntries64(t *testing.T) {
+	entries := randomEntries64(500)
+	entries = append(entries, Entry64[int]{0, 0, -1})
+	r := FromEntries64(entries)
+	got := r.Entries()
+	want := slices.Clone(entries)
+	sortEntries := func(e []Entry64[int]) {
+		slices.SortFunc(e, func(a, b Entry64[int]) int { return a.Value - b.Value })
+	}
+	sortEntries(got)
+	sortEntries(want)
+	if !reflect.DeepEqual(got, want) {
+		t.Logf("Expected Entries to return the entries given to FromEntries64\n")
+		t.Fail()
+	}
+	if !FromEntries64(r.Entries()).Equal(r, func(a, b int) bool { return a == b }) {
+		t.Logf("Expected a tree built from Entries to be equal\n")
+		t.Fail()
+	}
+}
+
+func TestCountPrefix(t *testing.T) {
+	r := New64[uint32]()
+	for i, route := range []string{"10.0.0.0/8", "10.1.0.0/16", "10.1.1.0/24", "10.2.0.0/16", "192.168.0.0/16"} {
+		addRoute64(t, r, route, uint32(i))
+	}
+	tests := []struct {
+		route string
+		want  int
+	}{
+		{"10.0.0.0/8", 4},
+		{"10.1.0.0/16", 2},
+		{"10.0.0.0/7", 4},
+		{"172.16.0.0/12", 0},
+		{"0.0.0.0/0", 5},
+	}
+	for _, tc := range tests {
+		_, ipnet, _ := net.ParseCIDR(tc.route)
+		n, bits := ipToUint64(t, ipnet)
+		if c := r.CountPrefix(n, bits); c != tc.want {
+			t.Logf("Expected %d keys below %s, got %d\n", tc.want, tc.route, c)
+			t.Fail()
+		}
+	}
+	r.Insert(0, 0, 0)
+	if c := r.CountPrefix(0, 0); c != 6 {
+		t.Logf("Expected the default route to be counted, got %d\n", c)
+		t.Fail()
+	}
+	if a := testing.AllocsPerRun(10, func() { r.CountPrefix(0x0A00000000000000, 8) }); a != 0 {
+		t.Logf("Expected no allocations, got %v\n", a)
+		t.Fail()
+	}
+}
+
+func TestGet(t *testing.T) {
+	r := New64[string]()
+	r.Insert(0x0A00000000000000, 8, "ten")
+	r.Insert(0x0A01000000000000, 16, "ten-one")
+	tests := map[uint64]string{
+		0x0A01020300000000: "ten-one",
+		0x0A02000000000000: "ten",
+		0xC0A8000100000000: "",
+	}
+	for n, want := range tests {
+		v, ok := r.Get(n)
+		if v != want || ok != (want != "") {
+			t.Logf("Expected %q, got %q (%t) for %016x\n", want, v, ok, n)
+			t.Fail()
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	r := New64[uint32]()
+	addRoute64(t, r, "10.0.0.0/8", 8)
+	addRoute64(t, r, "10.1.0.0/16", 16)
+	tests := map[string]uint32{
+		"10.1.255.255": 16,
+		"10.1.0.1":     16,
+		"10.2.3.4":     8,
+		"11.0.0.0":     0,
+	}
+	for ip, want := range tests {
+		n, _ := ipToUint64(t, &net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(32, 32)})
+		n |= 0xFFFFFFFF // host bits below the IPv4 address
+		x := r.Match(n)
+		if (x == nil) != (want == 0) || x != nil && x.Value != want {
+			t.Logf("Expected %d for %s, got %v\n", want, ip, x)
+			t.Fail()
+		}
+	}
+}
+
+func TestCoversAddr(t *testing.T) {
+	r := New64[uint32]()
+	addRoute64(t, r, "10.0.0.0/8", 8)
+	addRoute64(t, r, "192.168.1.0/24", 24)
+	tests := map[string]bool{
+		"10.1.2.3":    true,
+		"192.168.1.1": true,
+		"192.168.2.1": false,
+		"11.0.0.1":    false,
+	}
+	for ip, want := range tests {
+		n, _ := ipToUint64(t, &net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(32, 32)})
+		if got := r.CoversAddr(n); got != want {
+			t.Logf("Expected %t for %s, got %t\n", want, ip, got)
+			t.Fail()
+		}
+	}
+	addRoute64(t, r, "0.0.0.0/0", 0)
+	if !r.CoversAddr(0xFFFFFFFF00000000) {
+		t.Logf("Expected the default route to cover everything\n")
+		t.Fail()
+	}
+}
+
+func TestCommonAncestor(t *testing.T) {
+	r := New64[uint32]()
+	addRoute64(t, r, "10.0.0.0/8", 8)
+	addRoute64(t, r, "10.1.0.0/16", 16)
+	addRoute64(t, r, "10.1.2.0/24", 24)
+	a, _ := ipToUint64(t, &net.IPNet{IP: net.ParseIP("10.1.2.3"), Mask: net.CIDRMask(32, 32)})
+	b, _ := ipToUint64(t, &net.IPNet{IP: net.ParseIP("10.1.2.4"), Mask: net.CIDRMask(32, 32)})
+	if x := r.CommonAncestor(a, b); x.Value != 24 || x.Bits() != 24 {
+		t.Logf("Expected the node holding 10.1.2.0/24, got %x/%d\n", x.Key(), x.Bits())
+		t.Fail()
+	}
+	if x := r.CommonAncestor(a, a); x.Value != 24 {
+		t.Logf("Expected the node holding 10.1.2.0/24 for equal keys, got %x/%d\n", x.Key(), x.Bits())
+		t.Fail()
+	}
+
+	b, _ = ipToUint64(t, &net.IPNet{IP: net.ParseIP("192.168.1.1"), Mask: net.CIDRMask(32, 32)})
+	if x := r.CommonAncestor(a, b); x != r {
+		t.Logf("Expected the root for keys differing in the first bit, got %x/%d\n", x.Key(), x.Bits())
+		t.Fail()
+	}
+	if x := New64[uint32]().CommonAncestor(a, a); x.Bits() != 0 {
+		t.Logf("Expected the root of an empty tree, got %x/%d\n", x.Key(), x.Bits())
+		t.Fail()
+	}
+}
+
+func TestComparePrefix64(t *testing.T) {
+	e := func(n uint64, bits int) Entry64[int] { return Entry64[int]{n, bits, bits} }
+	tests := []struct {
+		a, b Entry64[int]
+		want int
+	}{
+		{e(0x0A00000000000000, 8), e(0x0A00000000000000, 16), -1}, // 10.0.0.0/8 < 10.0.0.0/16
+		{e(0x0A00000000000000, 8), e(0x0B00000000000000, 8), -1},  // 10.0.0.0/8 < 11.0.0.0/8
+		{e(0x0A00000000000000, 16), e(0x0B00000000000000, 8), -1}, // 10.0.0.0/16 < 11.0.0.0/8
+		{e(0x0A01000000000000, 16), e(0x0A00000000000000, 8), 1},
+		{e(0x0A00000000000000, 8), e(0x0A00000000000000, 8), 0},
+		{e(0x0AFF000000000000, 8), e(0x0A00000000000000, 16), -1}, // the network address of the first is 10.0.0.0
+		{e(0, 0), e(0x0A00000000000000, 8), -1},
+	}
+	for _, tc := range tests {
+		if got := ComparePrefix64(tc.a, tc.b); got != tc.want {
+			t.Logf("Expected %d for %x/%d and %x/%d, got %d\n", tc.want, tc.a.Key, tc.a.Bits, tc.b.Key, tc.b.Bits, got)
+			t.Fail()
+		}
+		if got := ComparePrefix64(tc.b, tc.a); got != -tc.want {
+			t.Logf("Expected %d for %x/%d and %x/%d, got %d\n", -tc.want, tc.b.Key, tc.b.Bits, tc.a.Key, tc.a.Bits, got)
+			t.Fail()
+		}
+	}
+
+	entries := []Entry64[int]{e(0x0B00000000000000, 8), e(0x0A00000000000000, 16), e(0x0A00000000000000, 8)}
+	slices.SortFunc(entries, ComparePrefix64)
+	want := []Entry64[int]{e(0x0A00000000000000, 8), e(0x0A00000000000000, 16), e(0x0B00000000000000, 8)}
+	if !reflect.DeepEqual(entries, want) {
+		t.Logf("Expected %v, got %v\n", want, entries)
+		t.Fail()
+	}
+}
+
+func TestFloorCeil(t *testing.T) {
+	r := New64[int]()
+	r.Insert(0x1000000000000000, 4, 1)
+	r.Insert(0x1000000000000000, 8, 2)
+	r.Insert(0x4000000000000000, 2, 3)
+	r.Insert(0x8100000000000000, 8, 4)
+	r.Insert(0, 0, 0) // the default route is not considered
+	tests := []struct {
+		n           uint64
+		floor, ceil int // values, 0 for nothing
+	}{
+		{0x1000000000000000, 2, 1},
+		{0x4000000000000000, 3, 3},
+		{0x2000000000000000, 2, 3},
+		{0x0FFFFFFFFFFFFFFF, 0, 1},
+		{0x8100000000000001, 4, 0},
+		{0xFFFFFFFFFFFFFFFF, 4, 0},
+	}
+	for _, tc := range tests {
+		x, ok := r.Floor(tc.n)
+		if ok != (tc.floor != 0) || ok && x.Value != tc.floor {
+			t.Logf("Expected floor %d for %016x, got %v\n", tc.floor, tc.n, x)
+			t.Fail()
+		}
+		x, ok = r.Ceil(tc.n)
+		if ok != (tc.ceil != 0) || ok && x.Value != tc.ceil {
+			t.Logf("Expected ceil %d for %016x, got %v\n", tc.ceil, tc.n, x)
+			t.Fail()
+		}
+	}
+
+	// Compare with the sorted keys.
+	r = New64[int]()
+	for _, e := range randomEntries64(500) {
+		r.Insert(e.Key, e.Bits, e.Value)
+	}
+	entries := r.Entries()
+	rnd := rand.New(rand.NewPCG(82, 82))
+	for i := 0; i < 1000; i++ {
+		n := rnd.Uint64()
+		if i%2 == 0 {
+			n = entries[rnd.IntN(len(entries))].Key + uint64(rnd.IntN(3)) - 1
+		}
+		i := sort.Search(len(entries), func(i int) bool { return entries[i].Key > n })
+		if x, ok := r.Floor(n); ok != (i > 0) || ok && (x.Key() != entries[i-1].Key || x.Bits() != entries[i-1].Bits) {
+			t.Logf("Expected a different floor for %016x\n", n)
+			t.Fail()
+		}
+		j := sort.Search(len(entries), func(i int) bool { return entries[i].Key >= n })
+		if x, ok := r.Ceil(n); ok != (j < len(entries)) || ok && (x.Key() != entries[j].Key || x.Bits() != entries[j].Bits) {
+			t.Logf("Expected a different ceil for %016x\n", n)
+			t.Fail()
+		}
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	type route struct {
+		ASN   uint32
+		Added time.Time
+	}
+	added := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	r := New64[route]()
+	addRoute := func(cidr string, asn uint32, at time.Time) {
+		_, ipnet, _ := net.ParseCIDR(cidr)
+		n, bits := ipToUint64(t, ipnet)
+		r.Insert(n, bits, route{asn, at})
+	}
+	addRoute("10.0.0.0/8", 64512, added)
+	addRoute("10.1.0.0/16", 64513, added.Add(time.Hour))
+	x := r.Find(0x0A01020000000000, 24)
+	if x == nil || x.Value.ASN != 64513 || !x.Value.Added.Equal(added.Add(time.Hour)) {
+		t.Logf("Expected the metadata of 10.1.0.0/16, got %v\n", x)
+		t.Fail()
+	}
+	// Update the metadata without touching the value.
+	r.Update(0x0A00000000000000, 8, func(old route, _ bool) route {
+		old.Added = added.Add(2 * time.Hour)
+		return old
+	})
+	if x := r.Find(0x0A00000000000000, 8); x.Value.ASN != 64512 || !x.Value.Added.Equal(added.Add(2*time.Hour)) {
+		t.Logf("Expected updated metadata, got %v\n", x.Value)
+		t.Fail()
+	}
+}
+
+func TestLookup(t *testing.T) {
+	r := New64[string]()
+	r.Insert(0x0A00000000000000, 8, "ten")
+	r.Insert(0x0A00000000000001, 64, "host")
+	tests := []struct {
+		n     uint64
+		v     string
+		bits  int
+		exact bool
+		found bool
+	}{
+		{0x0A00000000000001, "host", 64, true, true},
+		{0x0A00000000000002, "ten", 8, false, true},
+		{0x0B00000000000000, "", 0, false, false},
+	}
+	for _, tc := range tests {
+		v, bits, exact, found := r.Lookup(tc.n)
+		if v != tc.v || bits != tc.bits || exact != tc.exact || found != tc.found {
+			t.Logf("Expected %q %d %t %t for %016x, got %q %d %t %t\n", tc.v, tc.bits, tc.exact, tc.found, tc.n, v, bits, exact, found)
+			t.Fail()
+		}
+	}
+	if allocs := testing.AllocsPerRun(100, func() { r.Lookup(0x0A00000000000002) }); allocs != 0 {
+		t.Logf("Expected no allocations, got %f\n", allocs)
+		t.Fail()
+	}
+}
+
+func TestGetExact(t *testing.T) {
+	r := New64[int]()
+	rnd := rand.New(rand.NewPCG(81, 81))
+	hosts := make([]uint64, 500)
+	for i := range hosts {
+		hosts[i] = rnd.Uint64()
+		r.Insert(hosts[i], 64, i)
+	}
+	r.Insert(0x0A00000000000000, 8, -1)
+	for i, n := range hosts {
+		if v, ok := r.GetExact(n); !ok || v != i {
+			t.Logf("Expected %d for %016x, got %d (%t)\n", i, n, v, ok)
+			t.Fail()
+		}
+	}
+	// Covered by 10.0.0.0/8, but not stored as a host key.
+	if v, ok := r.GetExact(0x0A00000000000001); ok {
+		t.Logf("Expected no host key, got %d\n", v)
+		t.Fail()
+	}
+	if _, ok := r.GetExact(0x0A00000000000000); ok {
+		t.Logf("Expected the /8 not to match as a host key\n")
+		t.Fail()
+	}
+}
+
+func benchmarkHosts64(b *testing.B, get func(*Radix64[int], uint64) (int, bool)) {
+	r := New64[int]()
+	hosts := benchmarkAddrs64(10000)
+	for i, n := range hosts {
+		r.Insert(n, 64, i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		get(r, hosts[i%len(hosts)])
+	}
+}
+
+func BenchmarkGetHosts(b *testing.B) { benchmarkHosts64(b, (*Radix64[int]).Get) }
+
+func BenchmarkGetExactHosts(b *testing.B) { benchmarkHosts64(b, (*Radix64[int]).GetExact) }
+
+func TestGetWithLen(t *testing.T) {
+	r := New64[uint32]()
+	addRoute64(t, r, "10.0.0.0/8", 8)
+	addRoute64(t, r, "10.1.2.3/32", 32)
+	tests := []struct {
+		ip    string
+		v     uint32
+		bits  int
+		found bool
+	}{
+		{"10.1.2.3", 32, 32, true},
+		{"10.1.2.4", 8, 8, true},
+		{"192.168.0.1", 0, 0, false},
+	}
+	for _, tc := range tests {
+		n, _ := ipToUint64(t, &net.IPNet{IP: net.ParseIP(tc.ip), Mask: net.CIDRMask(32, 32)})
+		v, bits, found := r.GetWithLen(n)
+		if v != tc.v || bits != tc.bits || found != tc.found {
+			t.Logf("Expected %d/%d (%t) for %s, got %d/%d (%t)\n", tc.v, tc.bits, tc.found, tc.ip, v, bits, found)
+			t.Fail()
+		}
+	}
+}
+
+func TestNearest(t *testing.T) {
+	if _, ok := New64[int]().Nearest(42); ok {
+		t.Logf("Expected nothing in an empty tree\n")
+		t.Fail()
+	}
+	rnd := rand.New(rand.NewPCG(64, 64))
+	for _, size := range []int{1, 10, 500} {
+		r := New64[int]()
+		entries := randomEntries64(size)
+		for _, e := range entries {
+			r.Insert(e.Key, e.Bits, e.Value)
+		}
+		for i := 0; i < 500; i++ {
+			target := rnd.Uint64()
+			if i%2 == 0 {
+				target = entries[rnd.IntN(size)].Key ^ rnd.Uint64()>>rnd.IntN(64)
+			}
+			want := entries[0].Key
+			for _, e := range entries {
+				if e.Key^target < want^target {
+					want = e.Key
+				}
+			}
+			x, ok := r.Nearest(target)
+			if !ok || x.Key() != want {
+				t.Logf("Expected %016x for %016x, got %016x\n", want, target, x.Key())
+				t.Fail()
+				return
+			}
+		}
+	}
+}
+
+func TestKNearest(t *testing.T) {
+	rnd := rand.New(rand.NewPCG(65, 65))
+	r := New64[int]()
+	entries := randomEntries64(300)
+	for _, e := range entries {
+		r.Insert(e.Key, e.Bits, e.Value)
+	}
+	for i := 0; i < 200; i++ {
+		target := rnd.Uint64()
+		if i%2 == 0 {
+			target = entries[rnd.IntN(len(entries))].Key ^ rnd.Uint64()>>rnd.IntN(64)
+		}
+		dists := make([]uint64, len(entries))
+		for j, e := range entries {
+			dists[j] = e.Key ^ target
+		}
+		slices.Sort(dists)
+		for _, k := range []int{1, 5, 17} {
+			got := r.KNearest(target, k)
+			if len(got) != k {
+				t.Logf("Expected %d nodes, got %d\n", k, len(got))
+				t.Fail()
+				return
+			}
+			for j, x := range got {
+				if x.Key()^target != dists[j] {
+					t.Logf("Expected distance %016x at %d for %016x, got %016x\n", dists[j], j, target, x.Key()^target)
+					t.Fail()
+					return
+				}
+			}
+		}
+	}
+
+	small := New64[int]()
+	small.Insert(0x0A00000000000000, 8, 1)
+	small.Insert(0x0B00000000000000, 8, 2)
+	small.Insert(0xC0A8000000000000, 16, 3)
+	got := small.KNearest(0x0B01000000000000, 10)
+	if len(got) != 3 || got[0].Value != 2 || got[1].Value != 1 || got[2].Value != 3 {
+		t.Logf("Expected all 3 nodes ordered by distance, got %d\n", len(got))
+		t.Fail()
+	}
+	if got := small.KNearest(0, 0); len(got) != 0 {
+		t.Logf("Expected no nodes for k 0, got %d\n", len(got))
+		t.Fail()
+	}
+}
+
+func TestGetBatch(t *testing.T) {
+	r := New64[int]()
+	for _, e := range randomEntries64(500) {
+		r.Insert(e.Key, e.Bits, e.Value)
+	}
+	rnd := rand.New(rand.NewPCG(60, 60))
+	addrs := make([]uint64, 1000)
+	for i := range addrs {
+		addrs[i] = rnd.Uint64()
+		if i%3 == 0 {
+			addrs[i] = addrs[i]>>40<<40 | addrs[i]&0xFF
+		}
+	}
+	addrs = append(addrs, addrs[:10]...)
+	slices.Sort(addrs)
+
+	vals := r.GetBatch(addrs)
+	vals2, found := r.GetBatchOK(addrs)
+	for i, n := range addrs {
+		v, ok := r.Get(n)
+		if vals[i] != v || vals2[i] != v || found[i] != ok {
+			t.Logf("Expected %d (%t), got %d, %d (%t) for %016x\n", v, ok, vals[i], vals2[i], found[i], n)
+			t.Fail()
+		}
+	}
+}
+
+func benchmarkAddrs64(n int) []uint64 {
+	rnd := rand.New(rand.NewPCG(60, 64))
+	addrs := make([]uint64, n)
+	for i := range addrs {
+		addrs[i] = rnd.Uint64()
+	}
+	return addrs
+}
+
+func BenchmarkGetLoop(b *testing.B) {
+	r := New64[int]()
+	for _, e := range randomEntries64(10000) {
+		r.Insert(e.Key, e.Bits, e.Value)
+	}
+	addrs := benchmarkAddrs64(1000)
+	vals := make([]int, len(addrs))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j, n := range addrs {
+			vals[j], _ = r.Get(n)
+		}
+	}
+}
+
+func BenchmarkGetBatch(b *testing.B) {
+	r := New64[int]()
+	for _, e := range randomEntries64(10000) {
+		r.Insert(e.Key, e.Bits, e.Value)
+	}
+	addrs := benchmarkAddrs64(1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.GetBatch(addrs)
+	}
+}
+
+func TestForEachMut(t *testing.T) {
+	r := New64[int]()
+	entries := randomEntries64(200)
+	for _, e := range entries {
+		r.Insert(e.Key, e.Bits, e.Value+1)
+	}
+	r.Insert(0, 0, 42)
+	n := 0
+	r.ForEachMut(func(_ uint64, _ int, v *int) {
+		*v = 0
+		n++
+	})
+	if n != len(entries)+1 {
+		t.Logf("Expected %d keys, got %d\n", len(entries)+1, n)
+		t.Fail()
+	}
+	for _, e := range append(entries, Entry64[int]{0, 0, 0}) {
+		if x := r.Find(e.Key, e.Bits); x == nil || x.Value != 0 {
+			t.Logf("Expected %016x/%d to be zeroed\n", e.Key, e.Bits)
+			t.Fail()
+		}
+	}
+}
+
+func TestLengthHistogram(t *testing.T) {
+	r := New64[uint32]()
+	for _, cidr := range []string{"10.0.0.0/8", "11.0.0.0/8", "10.1.0.0/16", "10.2.0.0/16", "10.3.0.0/16", "10.1.2.0/24", "192.168.1.0/24", "192.168.2.0/24", "192.168.3.0/24", "192.168.4.0/24"} {
+		addRoute64(t, r, cidr, 0)
+	}
+	want := map[int]int{8: 2, 16: 3, 24: 5}
+	if got := r.LengthHistogram(); !reflect.DeepEqual(got, want) {
+		t.Logf("Expected %v, got %v\n", want, got)
+		t.Fail()
+	}
+	if got := New64[int]().LengthHistogram(); len(got) != 0 {
+		t.Logf("Expected an empty histogram, got %v\n", got)
+		t.Fail()
+	}
+}
+
+func TestReverseForEach(t *testing.T) {
+	r := New64[uint32]()
+	for _, cidr := range []string{"10.1.0.0/16", "0.0.0.0/0", "192.168.0.0/16", "10.0.0.0/8", "10.1.2.0/24", "11.0.0.0/8", "10.1.0.0/24", "192.168.1.0/24"} {
+		addRoute64(t, r, cidr, 0)
+	}
+	var got []string
+	r.ReverseForEach(func(key uint64, bits int, _ uint32) {
+		got = append(got, fmt.Sprintf("%s/%d", uintToIP(uint32(key>>32)), bits))
+	})
+	want := []string{"192.168.1.0/24", "192.168.0.0/16", "11.0.0.0/8", "10.1.2.0/24", "10.1.0.0/24", "10.1.0.0/16", "10.0.0.0/8", "0.0.0.0/0"}
+	if !slices.Equal(got, want) {
+		t.Logf("Expected %v, got %v\n", want, got)
+		t.Fail()
+	}
+}
+
+func TestAtLength(t *testing.T) {
+	r := New64[uint32]()
+	for _, cidr := range []string{"0.0.0.0/0", "10.0.0.0/8", "11.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24", "10.1.3.0/24", "192.168.1.0/24", "192.168.0.0/16"} {
+		addRoute64(t, r, cidr, 0)
+	}
+	tests := map[int]int{0: 1, 8: 2, 16: 2, 24: 3, 32: 0}
+	for bits, want := range tests {
+		n := 0
+		r.AtLength(bits, func(r1 *Radix64[uint32]) {
+			n++
+			if r1.Bits() != bits {
+				t.Logf("Expected only /%d keys, got %016x/%d\n", bits, r1.Key(), r1.Bits())
+				t.Fail()
+			}
+		})
+		if n != want {
+			t.Logf("Expected %d keys of /%d, got %d\n", want, bits, n)
+			t.Fail()
+		}
+	}
+}
+
+func TestDoDepth(t *testing.T) {
+	r := New64[int]()
+	r.Insert(0x8000000000000000, 1, 1)
+	r.Insert(0x4000000000000000, 2, 2)
+	r.Insert(0xC000000000000000, 2, 3)
+	r.Insert(0x2000000000000000, 3, 4)
+	var got []string
+	r.DoDepth(func(r1 *Radix64[int], i, depth int) {
+		got = append(got, fmt.Sprintf("%d %d %x/%d", depth, i, r1.Key()>>60, r1.Bits()))
+	})
+	want := []string{
+		"0 -1 8/1",
+		"1 0 4/2",
+		"1 1 c/2",
+		"2 0 2/3",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Logf("Expected %q, got %q\n", want, got)
+		t.Fail()
+	}
+}
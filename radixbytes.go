@@ -0,0 +1,320 @@
+package bitradix
+
+// RadixBytes implements a radix tree with a byte slice of arbitrary length as
+// its key. Bits are counted from the most significant bit of the first byte,
+// bits past the end of a key are zero.
+//
+// Unlike the other trees a key with zero significant bits (a default entry)
+// can be stored, it lives in the root and covers every key.
+type RadixBytes[T any] struct {
+	branch [2]*RadixBytes[T] // branch[0] is left branch for 0, and branch[1] the right for 1
+	parent *RadixBytes[T]
+	key    []byte // the key under which this value is stored, if nil the key has not been set.
+	bits   int    // the number of significant bits.
+	count  int    // the number of keys stored in the tree, only maintained in the root.
+	Value  T      // The value stored.
+}
+
+// NewBytes returns an empty, initialized RadixBytes tree.
+func NewBytes[T any]() *RadixBytes[T] {
+	r := &RadixBytes[T]{}
+	// It gets two branches by default
+	r.branch[0] = r.new()
+	r.branch[1] = r.new()
+	return r
+}
+
+// Key returns the key under which this node is stored. A nil key indicates a
+// key that has not been set.
+func (r *RadixBytes[_]) Key() []byte {
+	return r.key
+}
+
+// Bits returns the number of significant bits for the key.
+func (r *RadixBytes[_]) Bits() int {
+	return r.bits
+}
+
+// Leaf returns true is r is an leaf node, when false is returned
+// the node is a non-leaf node.
+func (r *RadixBytes[_]) Leaf() bool {
+	return r.branch[0] == nil && r.branch[1] == nil
+}
+
+// Insert inserts a new value n in the tree r (possibly silently overwriting an existing value).
+// It returns the inserted node, r must be the root of the tree. The key is
+// copied.
+func (r *RadixBytes[T]) Insert(n []byte, bits int, v T) *RadixBytes[T] {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+	if bits < 0 {
+		panic("bitradix: bits smaller than zero")
+	}
+
+	n = append(make([]byte, 0, len(n)), n...)
+	if x := r.exact(n, bits); x != nil { // equal keys, overwrite
+		x.set(n, bits, v)
+		return x
+	}
+	return r.insert(n, bits, v, 0)
+}
+
+// Remove removes a value from the tree r. It returns the node removed, or nil
+// when nothing is found, r must be the root of the tree.
+func (r *RadixBytes[T]) Remove(n []byte, bits int) *RadixBytes[T] {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	x := r.exact(n, bits)
+	if x == nil {
+		return nil
+	}
+	// save x in r1
+	r1 := &RadixBytes[T]{key: x.key, bits: x.bits, Value: x.Value}
+	x.prune(true)
+	return r1
+}
+
+// Find searches the tree for the key n, where the first bits bits of n
+// are significant. It returns the node found or the node with the longest
+// prefix covering n. It returns nil when nothing can be found.
+func (r *RadixBytes[T]) Find(n []byte, bits int) *RadixBytes[T] {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	var last *RadixBytes[T]
+	for bit := 0; r != nil; bit++ {
+		if r.key != nil && r.bits <= bits && matchBytes(r.key, n, r.bits) {
+			if r.bits == bits {
+				// our key
+				return r
+			}
+			if last == nil || r.bits > last.bits {
+				last = r
+			}
+		}
+		if bit >= bits {
+			break
+		}
+		r = r.branch[bitKBytes(n, bit)]
+	}
+	return last
+}
+
+// LongestPrefixMatch returns the node holding the most specific key that covers
+// n, all bits of n are used in the search. The boolean is false when no key
+// covers n. r must be the root of the tree.
+func (r *RadixBytes[T]) LongestPrefixMatch(n []byte) (*RadixBytes[T], bool) {
+	x := r.Find(n, len(n)*8)
+	return x, x != nil
+}
+
+// Len returns the number of keys stored in the tree r, r must be the root
+// of the tree.
+func (r *RadixBytes[T]) Len() int {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	return r.count
+}
+
+// Do traverses the tree r in breadth-first order. For each visited node,
+// the function f is called with the current node, and the branch taken
+// (0 for the zero, 1 for the one branch, -1 is used for the root node).
+func (r *RadixBytes[T]) Do(f func(*RadixBytes[T], int)) {
+	q := make(queueBytes[T], 0)
+
+	q.Push(&nodeBytes[T]{r, -1})
+	x := q.Pop()
+	for x != nil {
+		f(x.RadixBytes, x.branch)
+		for i, b := range x.RadixBytes.branch {
+			if b != nil {
+				q.Push(&nodeBytes[T]{b, i})
+			}
+		}
+		x = q.Pop()
+	}
+}
+
+// Implement insert. A node at depth bit only holds keys with at least bit
+// significant bits, so every key covering n is found along the path of n.
+func (r *RadixBytes[T]) insert(n []byte, bits int, v T, bit int) *RadixBytes[T] {
+	switch r.Leaf() {
+	case false: // Non-leaf node, one or two branches, possibly a key
+		if bits == bit { // I should be put here
+			if r.key != nil {
+				// move the current key down
+				n1, b1, v1 := r.key, r.bits, r.Value
+				r.set(n, bits, v)
+				bcur := bitKBytes(n1, bit)
+				if r.branch[bcur] == nil {
+					r.branch[bcur] = r.new()
+				}
+				r.branch[bcur].insert(n1, b1, v1, bit+1)
+				return r
+			}
+			r.set(n, bits, v)
+			return r
+		}
+		bnew := bitKBytes(n, bit)
+		if r.branch[bnew] == nil {
+			r.branch[bnew] = r.new()
+		}
+		return r.branch[bnew].insert(n, bits, v, bit+1)
+	case true: // External node, (optional) key, no branches
+		if r.key == nil { // nothing here yet, put something in
+			r.set(n, bits, v)
+			return r
+		}
+		if bits < r.bits {
+			// the shortest key stays here, the current key moves down
+			n1, b1, v1 := r.key, r.bits, r.Value
+			r.set(n, bits, v)
+			bcur := bitKBytes(n1, bit)
+			r.branch[bcur] = r.new()
+			r.branch[bcur].insert(n1, b1, v1, bit+1)
+			return r
+		}
+		bnew := bitKBytes(n, bit)
+		r.branch[bnew] = r.new()
+		return r.branch[bnew].insert(n, bits, v, bit+1)
+	}
+	panic("bitradix: not reached")
+}
+
+// Prune the tree, when b is true the current node is deleted.
+func (r *RadixBytes[T]) prune(b bool) {
+	if b {
+		r.clear()
+		if !r.Leaf() {
+			// our subtree stays, we may become redundant
+			r.prune(false)
+			return
+		}
+		if r.parent == nil {
+			return
+		}
+		// we are a node, we have a parent, so the parent is a non-leaf node
+		if r.parent.branch[0] == r {
+			// kill that branch
+			r.parent.branch[0] = nil
+		}
+		if r.parent.branch[1] == r {
+			r.parent.branch[1] = nil
+		}
+		r.parent.prune(false)
+		return
+	}
+	if r == nil {
+		return
+	}
+	if r.key != nil {
+		// fun stops
+		return
+	}
+	// Does I have one or two childeren, if one, move my self up one node
+	// Also the child must be a leaf node!
+	b0 := r.branch[0]
+	b1 := r.branch[1]
+	if b0 != nil && b1 != nil {
+		// two branches, we cannot replace ourselves with a child
+		return
+	}
+	for _, c := range []*RadixBytes[T]{b0, b1} {
+		if c == nil {
+			continue
+		}
+		if !c.Leaf() {
+			return
+		}
+		// move c into this node
+		r.set(c.key, c.bits, c.Value)
+		c.clear()
+		r.branch[0] = nil
+		r.branch[1] = nil
+	}
+	r.parent.prune(false)
+}
+
+// Walk the tree following the bits of n and return the node that holds
+// exactly n/bits, or nil when there is no such node.
+func (r *RadixBytes[T]) exact(n []byte, bits int) *RadixBytes[T] {
+	for bit := 0; r != nil && bit <= bits; bit++ {
+		if r.key != nil && r.bits == bits && matchBytes(r.key, n, bits) {
+			return r
+		}
+		r = r.branch[bitKBytes(n, bit)]
+	}
+	return nil
+}
+
+// Return a new node, with r as its parent
+func (r *RadixBytes[T]) new() *RadixBytes[T] {
+	return &RadixBytes[T]{parent: r}
+}
+
+func (r *RadixBytes[T]) set(key []byte, bits int, value T) {
+	switch {
+	case r.key == nil && key != nil:
+		r.root().count++
+	case r.key != nil && key == nil:
+		r.root().count--
+	}
+	r.key = key
+	r.bits = bits
+	r.Value = value
+}
+
+func (r *RadixBytes[T]) clear() {
+	var zero T
+
+	if r.key != nil {
+		r.root().count--
+	}
+	r.key = nil
+	r.bits = 0
+	r.Value = zero
+}
+
+// Return the root of the tree r is part of.
+func (r *RadixBytes[T]) root() *RadixBytes[T] {
+	for r.parent != nil {
+		r = r.parent
+	}
+	return r
+}
+
+// Return bit k from n. We count from the left, so k = 0 is the most significant
+// bit of n[0]. Bits past the end of n are zero.
+func bitKBytes(n []byte, k int) byte {
+	if k/8 >= len(n) {
+		return 0
+	}
+	return (n[k/8] >> uint(7-k%8)) & 1
+}
+
+// Return true when the first bits bits of a and b are equal.
+func matchBytes(a, b []byte, bits int) bool {
+	for i := 0; i < bits; i += 8 {
+		var x, y byte
+		if i/8 < len(a) {
+			x = a[i/8]
+		}
+		if i/8 < len(b) {
+			y = b[i/8]
+		}
+		if bits-i < 8 {
+			m := byte(0xFF << uint(8-(bits-i)))
+			x, y = x&m, y&m
+		}
+		if x != y {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,170 @@
+package bitradix
+
+import (
+	"math/rand/v2"
+	"testing"
+)
+
+func TestBitKBytes(t *testing.T) {
+	n := []byte{0x80, 0x01}
+	tests := map[int]byte{0: 1, 1: 0, 7: 0, 8: 0, 15: 1, 16: 0, 100: 0}
+	for k, expected := range tests {
+		if x := bitKBytes(n, k); x != expected {
+			t.Logf("Expected %d for bit #%d, got %d\n", expected, k, x)
+			t.Fail()
+		}
+	}
+}
+
+func TestMatchBytes(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		bits  int
+		match bool
+	}{
+		{"abc", "abd", 16, true},
+		{"abc", "abd", 24, false},
+		{"abc", "abd", 21, true}, // 'c' and 'd' differ in bit 22
+		{"ab", "ab\x00", 24, true},
+		{"ab", "abc", 24, false},
+		{"", "anything", 0, true},
+	}
+	for _, test := range tests {
+		if x := matchBytes([]byte(test.a), []byte(test.b), test.bits); x != test.match {
+			t.Logf("Expected %v for %q and %q (%d bits), got %v\n", test.match, test.a, test.b, test.bits, x)
+			t.Fail()
+		}
+	}
+}
+
+func TestFindBytes(t *testing.T) {
+	type entry struct {
+		key  string
+		bits int
+	}
+	entries := []entry{
+		{"", 0},        // default entry
+		{"a", 8},       // one byte
+		{"ab", 16},     // two bytes
+		{"abc", 24},    // three bytes
+		{"abc", 20},    // not on a byte boundary
+		{"abcdef", 48}, // longer key
+		{"b", 3},       // shorter than a byte
+		{"zzz", 24},
+	}
+	r := NewBytes[string]()
+	for _, e := range entries {
+		r.Insert([]byte(e.key), e.bits, e.key)
+	}
+	r.Do(func(r1 *RadixBytes[string], i int) { t.Logf("(%2d): %q/%d -> %q\n", i, r1.key, r1.bits, r1.Value) })
+	if l := r.Len(); l != len(entries) {
+		t.Logf("Expected %d, got %d\n", len(entries), l)
+		t.Fail()
+	}
+	for _, e := range entries {
+		x := r.Find([]byte(e.key), e.bits)
+		if x == nil || x.Bits() != e.bits || string(x.Key()) != e.key {
+			t.Logf("Expected %q/%d, got %v\n", e.key, e.bits, x)
+			t.Fail()
+		}
+	}
+
+	tests := map[string]entry{
+		"abcdefg": {"abcdef", 48},
+		"abcd":    {"abc", 24},
+		"abx":     {"ab", 16},
+		"a":       {"a", 8},
+		"ax":      {"a", 8},
+		"bar":     {"b", 3},
+		"c":       {"b", 3}, // 'b' and 'c' share the first 6 bits
+		"zzzz":    {"zzz", 24},
+		"zz":      {"b", 3}, // as is 'z'
+		"0":       {"", 0},
+		"\xff":    {"", 0},
+		"":        {"", 0},
+	}
+	for key, e := range tests {
+		x, ok := r.LongestPrefixMatch([]byte(key))
+		if !ok || x.Bits() != e.bits || string(x.Key()) != e.key {
+			t.Logf("Expected %q/%d for %q, got %v\n", e.key, e.bits, key, x)
+			t.Fail()
+		}
+	}
+
+	// Remove the default entry, now some keys do not match anything.
+	if x := r.Remove(nil, 0); x == nil || x.Value != "" {
+		t.Logf("Failed to remove the default entry\n")
+		t.Fail()
+	}
+	if _, ok := r.LongestPrefixMatch([]byte("0")); ok {
+		t.Logf("Expected no match after removing the default entry\n")
+		t.Fail()
+	}
+	for _, e := range entries[1:] {
+		if x := r.Remove([]byte(e.key), e.bits); x == nil || x.Value != e.key {
+			t.Logf("Failed to remove %q/%d\n", e.key, e.bits)
+			t.Fail()
+		}
+		if x := r.Find([]byte(e.key), e.bits); x != nil && x.Bits() == e.bits {
+			t.Logf("Found %q/%d after removal\n", e.key, e.bits)
+			t.Fail()
+		}
+	}
+	if l := r.Len(); l != 0 {
+		t.Logf("Expected %d, got %d\n", 0, l)
+		t.Fail()
+	}
+}
+
+func TestFindBytesRandom(t *testing.T) {
+	type entry struct {
+		key  []byte
+		bits int
+	}
+	rnd := rand.New(rand.NewPCG(1, 2))
+	r := NewBytes[int]()
+	var entries []entry
+	for i := 0; i < 500; i++ {
+		key := make([]byte, rnd.IntN(4))
+		for j := range key {
+			key[j] = byte(rnd.IntN(4)) << 6 // few distinct values, many shared prefixes
+		}
+		bits := rnd.IntN(len(key)*8 + 1)
+		if x := r.Find(key, bits); x == nil || x.Bits() != bits { // not seen before
+			entries = append(entries, entry{key, bits})
+		}
+		r.Insert(key, bits, bits)
+	}
+	if l := r.Len(); l != len(entries) {
+		t.Logf("Expected %d, got %d\n", len(entries), l)
+		t.Fail()
+	}
+	for i := 0; i < 500; i++ {
+		key := make([]byte, rnd.IntN(4))
+		for j := range key {
+			key[j] = byte(rnd.IntN(4)) << 6
+		}
+		// brute force longest prefix match
+		best := -1
+		for _, e := range entries {
+			if e.bits <= len(key)*8 && e.bits > best && matchBytes(e.key, key, e.bits) {
+				best = e.bits
+			}
+		}
+		x, ok := r.LongestPrefixMatch(key)
+		if ok != (best >= 0) || (ok && x.Bits() != best) {
+			t.Logf("Expected /%d for %08b, got %v\n", best, key, x)
+			t.Fail()
+		}
+	}
+	for _, e := range entries {
+		if x := r.Remove(e.key, e.bits); x == nil {
+			t.Logf("Failed to remove %08b/%d\n", e.key, e.bits)
+			t.Fail()
+		}
+	}
+	if l := r.Len(); l != 0 {
+		t.Logf("Expected %d, got %d\n", 0, l)
+		t.Fail()
+	}
+}
@@ -0,0 +1,47 @@
+package bitradix
+
+// ReadOnlyRadix64 is a view on a Radix64 tree that only allows lookups, it has
+// no methods that change the tree. It returns values instead of nodes, as a
+// node leads back to the root of the tree through Root. Changes made to the
+// tree itself are seen by the view.
+type ReadOnlyRadix64[T any] struct {
+	r *Radix64[T]
+}
+
+// ReadOnly returns a read-only view on the tree r, r must be the root of the
+// tree.
+func (r *Radix64[T]) ReadOnly() ReadOnlyRadix64[T] {
+	if r.parent != nil {
+		panic("bitradix: not the root node")
+	}
+
+	return ReadOnlyRadix64[T]{r: r}
+}
+
+// Find works like Radix64.Find, but returns the value of the node found. The
+// boolean is false when nothing is found.
+func (v ReadOnlyRadix64[T]) Find(n uint64, bits int) (T, bool) {
+	return value64(v.r.Find(n, bits))
+}
+
+// Get works like Radix64.Get.
+func (v ReadOnlyRadix64[T]) Get(n uint64) (T, bool) {
+	return v.r.Get(n)
+}
+
+// Match works like Radix64.Match, but returns the value and number of bits of
+// the key found. The boolean is false when no key covers n.
+func (v ReadOnlyRadix64[T]) Match(n uint64) (T, int, bool) {
+	return v.r.GetWithLen(n)
+}
+
+// Do calls f with the key, bits and value of every key in the tree, see
+// Radix64.ForEach.
+func (v ReadOnlyRadix64[T]) Do(f func(key uint64, bits int, v T)) {
+	v.r.ForEach(f)
+}
+
+// Len returns the number of keys stored in the tree.
+func (v ReadOnlyRadix64[T]) Len() int {
+	return v.r.Len()
+}
@@ -0,0 +1,83 @@
+package bitradix
+
+import "sync"
+
+// SafeRadix64 wraps a Radix64 with a read/write lock, so it can be used by
+// multiple goroutines at the same time. Insert and Remove take the write lock,
+// the other methods the read lock.
+//
+// As a node handed out by the tree can be changed by a concurrent writer, the
+// lookup methods return a copy of the value instead of the node.
+type SafeRadix64[T any] struct {
+	mu sync.RWMutex
+	r  *Radix64[T]
+}
+
+// NewSafe64 returns an empty, initialized SafeRadix64 tree.
+func NewSafe64[T any]() *SafeRadix64[T] {
+	return &SafeRadix64[T]{r: New64[T]()}
+}
+
+// Insert inserts a new value n in the tree s (possibly silently overwriting an
+// existing value).
+func (s *SafeRadix64[T]) Insert(n uint64, bits int, v T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.r.Insert(n, bits, v)
+}
+
+// Remove removes a value from the tree s. It returns the value removed, the
+// boolean is false when nothing is found.
+func (s *SafeRadix64[T]) Remove(n uint64, bits int) (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return value64(s.r.Remove(n, bits))
+}
+
+// Find works like Radix64.Find, but returns the value of the node found. The
+// boolean is false when nothing is found.
+func (s *SafeRadix64[T]) Find(n uint64, bits int) (T, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return value64(s.r.Find(n, bits))
+}
+
+// LongestPrefixMatch works like Radix64.LongestPrefixMatch, but returns the
+// value of the node found.
+func (s *SafeRadix64[T]) LongestPrefixMatch(n uint64) (T, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	x, _ := s.r.LongestPrefixMatch(n)
+	return value64(x)
+}
+
+// Do traverses the tree s in breadth-first order, see Radix64.Do. The read
+// lock is held during the traversal, f must not modify the tree or retain
+// the nodes it is given.
+func (s *SafeRadix64[T]) Do(f func(*Radix64[T], int)) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	s.r.Do(f)
+}
+
+// Len returns the number of keys stored in the tree s.
+func (s *SafeRadix64[T]) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.r.Len()
+}
+
+// Return the value of r, or the zero value and false when r is nil.
+func value64[T any](r *Radix64[T]) (T, bool) {
+	if r == nil {
+		var zero T
+		return zero, false
+	}
+	return r.Value, true
+}
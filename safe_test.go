@@ -0,0 +1,73 @@
+package bitradix
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSafeRadix64(t *testing.T) {
+	s := NewSafe64[uint64]()
+	s.Insert(0x0A00000000000000, 8, 10)
+	if v, ok := s.LongestPrefixMatch(0x0A01020300000000); !ok || v != 10 {
+		t.Logf("Expected %d, got %d\n", 10, v)
+		t.Fail()
+	}
+	if v, ok := s.Find(0x0A00000000000000, 8); !ok || v != 10 {
+		t.Logf("Expected %d, got %d\n", 10, v)
+		t.Fail()
+	}
+	if v, ok := s.Remove(0x0A00000000000000, 8); !ok || v != 10 {
+		t.Logf("Expected %d, got %d\n", 10, v)
+		t.Fail()
+	}
+	if _, ok := s.Remove(0x0A00000000000000, 8); ok {
+		t.Logf("Expected nothing to remove\n")
+		t.Fail()
+	}
+	if l := s.Len(); l != 0 {
+		t.Logf("Expected %d, got %d\n", 0, l)
+		t.Fail()
+	}
+}
+
+// Run with -race.
+func TestSafeRadix64Concurrent(t *testing.T) {
+	s := NewSafe64[uint64]()
+	var wg sync.WaitGroup
+	for w := uint64(0); w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := uint64(0); k < 256; k++ {
+				s.Insert((w<<8|k)<<48, 16, k)
+				if k%2 == 0 {
+					s.Remove((w<<8|k)<<48, 16)
+				}
+			}
+		}()
+	}
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := uint64(0); k < 1024; k++ {
+				s.LongestPrefixMatch(k << 48)
+				s.Find(k<<48, 16)
+				s.Len()
+				s.Do(func(r1 *Radix64[uint64], _ int) { _ = r1.Value })
+			}
+		}()
+	}
+	wg.Wait()
+	if l := s.Len(); l != 4*128 {
+		t.Logf("Expected %d, got %d\n", 4*128, l)
+		t.Fail()
+	}
+	for k := uint64(0); k < 1024; k++ {
+		v, ok := s.Find(k<<48, 16)
+		if ok != (k%2 == 1) || (ok && v != k&0xFF) {
+			t.Logf("Expected %d for %d, got %d (%v)\n", k&0xFF, k, v, ok)
+			t.Fail()
+		}
+	}
+}
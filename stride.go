@@ -0,0 +1,172 @@
+package bitradix
+
+// Stride64 implements a multibit radix tree with an uint64 as its key. Each
+// node consumes stride bits of the key and has 1<<stride branches, so a lookup
+// visits at most 64/stride+1 nodes instead of up to 64.
+//
+// A key of bits bits is stored in the node at depth bits/stride, in a slot for
+// the remaining bits%stride bits. Keys whose length is not a multiple of the
+// stride are not expanded, a node holds one slot for every prefix of its
+// stride. A bits of zero is a valid key that covers everything.
+type Stride64[T any] struct {
+	root   *stride64[T]
+	stride int
+	count  int
+}
+
+// A node of a Stride64 tree. The slot of a prefix of l bits (l < stride) with
+// value p is (1<<l)-1+p, so there are (1<<stride)-1 slots.
+type stride64[T any] struct {
+	branch []*stride64[T]
+	used   []bool
+	values []T
+	keys   int // the number of used slots
+	kids   int // the number of branches that are not nil
+}
+
+// New64Stride returns an empty, initialized Stride64 tree consuming stride bits
+// per node. It panics when stride is not in the range [1, 8].
+func New64Stride[T any](stride int) *Stride64[T] {
+	if stride < 1 || stride > 8 {
+		panic("bitradix: stride not in [1, 8]")
+	}
+	return &Stride64[T]{root: &stride64[T]{}, stride: stride}
+}
+
+// Stride returns the number of bits each node of s consumes.
+func (s *Stride64[T]) Stride() int {
+	return s.stride
+}
+
+// Insert inserts the value v under the key n, where the first bits bits of n
+// are significant, possibly overwriting an existing value. The other bits of n
+// are ignored.
+func (s *Stride64[T]) Insert(n uint64, bits int, v T) {
+	if err := checkBits64(n, bits); err != nil {
+		panic(err)
+	}
+
+	x := s.root
+	for d := 0; d < bits/s.stride; d++ {
+		c, _ := s.chunk(n, d)
+		if x.branch == nil {
+			x.branch = make([]*stride64[T], 1<<s.stride)
+		}
+		if x.branch[c] == nil {
+			x.branch[c] = &stride64[T]{}
+			x.kids++
+		}
+		x = x.branch[c]
+	}
+	i := s.slot(n, bits)
+	if x.used == nil {
+		x.used = make([]bool, 1<<s.stride-1)
+		x.values = make([]T, 1<<s.stride-1)
+	}
+	if !x.used[i] {
+		x.used[i] = true
+		x.keys++
+		s.count++
+	}
+	x.values[i] = v
+}
+
+// Remove removes the key n/bits from the tree s. It returns the value removed,
+// the boolean is false when nothing is found. Nodes left without keys and
+// branches are removed as well.
+func (s *Stride64[T]) Remove(n uint64, bits int) (T, bool) {
+	var zero T
+	if checkBits64(n, bits) != nil {
+		return zero, false
+	}
+
+	path := make([]*stride64[T], 0, bits/s.stride+1)
+	x := s.root
+	for d := 0; d < bits/s.stride; d++ {
+		if x.branch == nil {
+			return zero, false
+		}
+		c, _ := s.chunk(n, d)
+		if x.branch[c] == nil {
+			return zero, false
+		}
+		path = append(path, x)
+		x = x.branch[c]
+	}
+	i := s.slot(n, bits)
+	if x.used == nil || !x.used[i] {
+		return zero, false
+	}
+	v := x.values[i]
+	x.used[i] = false
+	x.values[i] = zero
+	x.keys--
+	s.count--
+	for d := len(path) - 1; d >= 0 && x.keys == 0 && x.kids == 0; d-- {
+		c, _ := s.chunk(n, d)
+		x = path[d]
+		x.branch[c] = nil
+		x.kids--
+	}
+	return v, true
+}
+
+// Find returns the value of the key n/bits, or else of the longest key that
+// covers n/bits. The boolean is false when nothing can be found.
+func (s *Stride64[T]) Find(n uint64, bits int) (T, bool) {
+	var (
+		v  T
+		ok bool
+	)
+	if checkBits64(n, bits) != nil {
+		return v, false
+	}
+	x := s.root
+	for d := 0; x != nil; d++ {
+		c, w := s.chunk(n, d)
+		if x.used != nil {
+			// the longest prefix in this node, that is not longer than bits
+			for l := min(w, s.stride-1, bits-d*s.stride); l >= 0; l-- {
+				if i := 1<<l - 1 + int(c>>uint(w-l)); x.used[i] {
+					v, ok = x.values[i], true
+					break
+				}
+			}
+		}
+		if x.branch == nil || (d+1)*s.stride > bits {
+			break
+		}
+		x = x.branch[c]
+	}
+	return v, ok
+}
+
+// LongestPrefixMatch returns the value of the most specific key that covers n,
+// all 64 bits of n are used in the search. The boolean is false when no key
+// covers n.
+func (s *Stride64[T]) LongestPrefixMatch(n uint64) (T, bool) {
+	return s.Find(n, bitSize64)
+}
+
+// Len returns the number of keys stored in the tree s.
+func (s *Stride64[T]) Len() int {
+	return s.count
+}
+
+// Return the bits of n a node at depth d consumes, and how many there are.
+// This is stride bits, except at the bottom of the tree when 64 is not a
+// multiple of the stride.
+func (s *Stride64[T]) chunk(n uint64, d int) (uint64, int) {
+	w := min(s.stride, bitSize64-d*s.stride)
+	if w <= 0 {
+		return 0, 0
+	}
+	return n << uint(d*s.stride) >> uint(bitSize64-w), w
+}
+
+// Return the slot of the key n/bits in its node.
+func (s *Stride64[T]) slot(n uint64, bits int) int {
+	d, l := bits/s.stride, bits%s.stride
+	c, w := s.chunk(n, d)
+	return 1<<l - 1 + int(c>>uint(w-l))
+}
@@ -0,0 +1,96 @@
+package bitradix
+
+import (
+	"math/rand/v2"
+	"testing"
+)
+
+func TestStride64(t *testing.T) {
+	entries := randomEntries64(1000)
+	rnd := rand.New(rand.NewPCG(86, 86))
+	for i := 0; i < 50; i++ { // host keys, and keys at the bottom of the tree
+		entries = append(entries, Entry64[int]{rnd.Uint64(), 64, -i}, Entry64[int]{rnd.Uint64() &^ 1, 63, -i - 100})
+	}
+	entries = append(entries, Entry64[int]{0, 0, -1000})
+
+	addrs := make([]uint64, 2000)
+	for i := range addrs {
+		addrs[i] = rnd.Uint64()
+		if i%2 == 0 {
+			e := entries[rnd.IntN(len(entries))]
+			addrs[i] = e.Key | addrs[i]>>e.Bits
+		}
+	}
+	for _, stride := range []int{1, 2, 3, 4, 5, 8} {
+		s := New64Stride[int](stride)
+		for _, e := range entries {
+			s.Insert(e.Key, e.Bits, e.Value)
+		}
+		if s.Len() != len(entries) {
+			t.Logf("Expected %d keys with stride %d, got %d\n", len(entries), stride, s.Len())
+			t.Fail()
+		}
+		same := func(what string, entries []Entry64[int]) {
+			for _, n := range addrs {
+				want, bits := 0, -1
+				for _, e := range entries {
+					if e.Bits > bits && NormalizeKey64(n, e.Bits) == NormalizeKey64(e.Key, e.Bits) {
+						want, bits = e.Value, e.Bits
+					}
+				}
+				if v, ok := s.LongestPrefixMatch(n); ok != (bits >= 0) || v != want {
+					t.Logf("Expected %d for %016x with stride %d %s, got %d (%t)\n", want, n, stride, what, v, ok)
+					t.Fail()
+					return
+				}
+			}
+			for _, e := range entries {
+				if v, ok := s.Find(e.Key, e.Bits); !ok || v != e.Value {
+					t.Logf("Expected to find %016x/%d with stride %d %s\n", e.Key, e.Bits, stride, what)
+					t.Fail()
+					return
+				}
+			}
+		}
+		same("after insert", entries)
+		for _, e := range entries[:500] {
+			if v, ok := s.Remove(e.Key, e.Bits); !ok || v != e.Value {
+				t.Logf("Expected to remove %016x/%d with stride %d\n", e.Key, e.Bits, stride)
+				t.Fail()
+			}
+		}
+		if _, ok := s.Remove(entries[0].Key, entries[0].Bits); ok || s.Len() != len(entries)-500 {
+			t.Logf("Expected %d keys with stride %d, got %d\n", len(entries)-500, stride, s.Len())
+			t.Fail()
+		}
+		same("after remove", entries[500:])
+	}
+}
+
+func TestStride64Panic(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Logf("Expected a panic for stride 9\n")
+			t.Fail()
+		}
+	}()
+	New64Stride[int](9)
+}
+
+func benchmarkStride64(b *testing.B, stride int) {
+	s := New64Stride[int](stride)
+	for _, e := range randomEntries64(10000) {
+		s.Insert(e.Key, e.Bits, e.Value)
+	}
+	addrs := benchmarkAddrs64(1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.LongestPrefixMatch(addrs[i%len(addrs)])
+	}
+}
+
+func BenchmarkStride64_1(b *testing.B) { benchmarkStride64(b, 1) }
+
+func BenchmarkStride64_2(b *testing.B) { benchmarkStride64(b, 2) }
+
+func BenchmarkStride64_4(b *testing.B) { benchmarkStride64(b, 4) }